@@ -0,0 +1,64 @@
+/*
+	付款银行类型
+*/
+
+package weixin
+
+import (
+	"strings"
+)
+
+// BankType 付款银行类型, 如CFT、ICBC_DEBIT、CMB_CREDIT
+type BankType string
+
+const BankTypeCFT BankType = "CFT" // 零钱
+
+var bankNames = map[string]string{
+	"ICBC":  "工商银行",
+	"ABC":   "农业银行",
+	"BOC":   "中国银行",
+	"CCB":   "建设银行",
+	"COMM":  "交通银行",
+	"PSBC":  "邮政储蓄银行",
+	"CMB":   "招商银行",
+	"CMBC":  "民生银行",
+	"CITIC": "中信银行",
+	"CEB":   "光大银行",
+	"CIB":   "兴业银行",
+	"SPDB":  "浦发银行",
+	"GDB":   "广发银行",
+	"PAB":   "平安银行",
+	"HXB":   "华夏银行",
+	"BOSH":  "上海银行",
+}
+
+func (this BankType) IsCredit() bool {
+	return strings.HasSuffix(string(this), "_CREDIT")
+}
+
+func (this BankType) IsDebit() bool {
+	return strings.HasSuffix(string(this), "_DEBIT")
+}
+
+// BankName 银行名称及卡类型, 未收录的类型返回原始值
+func (this BankType) BankName() string {
+	if this == BankTypeCFT {
+		return "零钱"
+	}
+	code := string(this)
+	i := strings.LastIndex(code, "_")
+	if i < 0 {
+		return code
+	}
+	name, ok := bankNames[code[:i]]
+	if !ok {
+		return code
+	}
+	switch {
+	case this.IsCredit():
+		return name + "(信用卡)"
+	case this.IsDebit():
+		return name + "(借记卡)"
+	}
+	return code
+}
@@ -1,8 +1,16 @@
 package weixin
 
 import (
+	"bytes"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,6 +19,22 @@ import (
 
 const TimeZoneOffsetCN = 8 * 60 * 60
 
+// ChinaTimeLayout 微信时间格式yyyyMMddHHmmss
+const ChinaTimeLayout = "20060102150405"
+
+// ChinaLocation 北京时间(UTC+8, 无夏令时)
+var ChinaLocation = time.FixedZone("CST", TimeZoneOffsetCN)
+
+// ParseChinaTime 按北京时间解析微信yyyyMMddHHmmss格式的时间(如time_end)
+func ParseChinaTime(s string) (time.Time, error) {
+	return time.ParseInLocation(ChinaTimeLayout, s, ChinaLocation)
+}
+
+const (
+	SignTypeMD5        string = "MD5"
+	SignTypeHMACSHA256 string = "HMAC-SHA256"
+)
+
 func ChinaTimestamp() string {
 	return fmt.Sprintf("%d", time.Now().Unix()+TimeZoneOffsetCN)
 }
@@ -21,7 +45,100 @@ func NonceStr() (nonceStr string) {
 	return
 }
 
-func SortAndConcat(pm map[string]interface{}) string {
+// NormalizeClientIP 将RemoteAddr等形式(1.2.3.4:5678、[::1]:443)转换为spbill_create_ip可用的IPv4/IPv6地址
+func NormalizeClientIP(remoteAddr string) string {
+	addr := strings.TrimSpace(remoteAddr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	return addr
+}
+
+// ValidClientIP spbill_create_ip须为不带端口的IPv4/IPv6地址, 可先经NormalizeClientIP转换
+func ValidClientIP(ip string) bool {
+	return net.ParseIP(ip) != nil
+}
+
+// DefaultTimeout 请求微信接口的HTTP超时(含建立连接、发送请求及读取响应), 0为不超时.
+// ctx的截止时间更早时以ctx为准
+var DefaultTimeout = 30 * time.Second
+
+// VerifyResponses 是否校验微信返回报文的签名
+var VerifyResponses = false
+
+var ErrSignatureMismatch = errors.New("Signature mismatch")
+
+// SignatureMismatchError 签名不一致, errors.Is(err, ErrSignatureMismatch)成立
+type SignatureMismatchError struct {
+	Expected string // 本地计算的签名
+	Received string // 报文中的签名
+}
+
+func (this *SignatureMismatchError) Error() string {
+	return fmt.Sprintf("Signature mismatch: expected %s, received %s", this.Expected, this.Received)
+}
+
+func (this *SignatureMismatchError) Unwrap() error {
+	return ErrSignatureMismatch
+}
+
+// VerifyResponseSign 校验微信返回报文的签名, return_code非SUCCESS时报文不带签名, 不做校验
+func VerifyResponseSign(body []byte, secretKey string, signType string) error {
+	params, err := XMLToMap(body)
+	if err != nil {
+		return err
+	}
+	if params["return_code"] != "SUCCESS" {
+		return nil
+	}
+	pm := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if k != "sign" {
+			pm[k] = v
+		}
+	}
+	expected := SignWithType(pm, secretKey, signType)
+	if expected != strings.ToUpper(params["sign"]) {
+		return &SignatureMismatchError{Expected: expected, Received: params["sign"]}
+	}
+	return nil
+}
+
+// SecretKeyLength 商户平台设置的API密钥长度
+const SecretKeyLength = 32
+
+// CheckSecretKey 签名前检查API密钥, 避免以空密钥签名后才被微信拒绝
+func CheckSecretKey(sk string) error {
+	if sk == "" {
+		return errors.New("Missing API secret key")
+	}
+	if len(sk) != SecretKeyLength {
+		return errors.New("Invalid API secret key: must be 32 characters")
+	}
+	return nil
+}
+
+// toParams 将请求结构体按json tag转为待签名参数, 数值保留原始字面量,
+// 避免float64格式化为科学计数法(如1e+06)导致签名错误
+func toParams(payload interface{}) (pm map[string]interface{}, err error) {
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	pm = make(map[string]interface{})
+	err = decoder.Decode(&pm)
+	return
+}
+
+// CanonicalString 按参数名排序拼接的待签名字符串(不含&key=), 空值不参与,
+// 可交由外部签名服务(如HSM)计算签名
+func CanonicalString(pm map[string]interface{}) string {
 	keys := []string{}
 	for k, v := range pm {
 		if v != "" {
@@ -37,9 +154,65 @@ func SortAndConcat(pm map[string]interface{}) string {
 	return strings.Join(params, "&")
 }
 
+func SortAndConcat(pm map[string]interface{}) string {
+	return CanonicalString(pm)
+}
+
 func Sign(pm map[string]interface{}, sk string) string {
-	str := SortAndConcat(pm)
+	str := CanonicalString(pm)
+	debugSignString(str)
 	str += "&key=" + sk
-	fmt.Println("Prepare signature:", str)
 	return fmt.Sprintf("%X", md5.Sum([]byte(str)))
 }
+
+// SignWithType 按签名类型签名, 未知类型按MD5处理
+func SignWithType(pm map[string]interface{}, sk string, signType string) string {
+	if signType != SignTypeHMACSHA256 {
+		return Sign(pm, sk)
+	}
+	str := CanonicalString(pm)
+	debugSignString(str)
+	str += "&key=" + sk
+	mac := hmac.New(sha256.New, []byte(sk))
+	mac.Write([]byte(str))
+	return fmt.Sprintf("%X", mac.Sum(nil))
+}
+
+// trimXMLBody 去除网关或代理在报文前添加的UTF-8 BOM及空白
+func trimXMLBody(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	return bytes.TrimSpace(body)
+}
+
+// XMLToMap 将微信单层<xml>报文解析为map
+func XMLToMap(body []byte) (params map[string]string, err error) {
+	params = make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(trimXMLBody(body)))
+	depth := 0
+	key := ""
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			return
+		}
+		if tokenErr != nil {
+			err = tokenErr
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				key = t.Name.Local
+				params[key] = ""
+			}
+		case xml.CharData:
+			if depth == 2 {
+				params[key] += string(t)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package weixin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeClientIP(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"1.2.3.4", "1.2.3.4"},
+		{"1.2.3.4:5678", "1.2.3.4"},
+		{" 1.2.3.4 ", "1.2.3.4"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"[::1]", "::1"},
+		{"[::1]:443", "::1"},
+		{"[2001:db8::1]:5678", "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"", ""},
+		{"   ", ""},
+		{"localhost:80", "localhost"},
+	}
+	for _, c := range cases {
+		if got := NormalizeClientIP(c.in); got != c.want {
+			t.Errorf("NormalizeClientIP(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestValidClientIP(t *testing.T) {
+	cases := []struct {
+		in    string
+		valid bool
+	}{
+		{"1.2.3.4", true},
+		{"2001:db8::1", true},
+		{"::1", true},
+		{"1.2.3.4:5678", false},
+		{"[::1]", false},
+		{" 1.2.3.4", false},
+		{"", false},
+		{"1.2.3", false},
+		{"localhost", false},
+		{"1.2.3.4.5", false},
+	}
+	for _, c := range cases {
+		if got := ValidClientIP(c.in); got != c.valid {
+			t.Errorf("ValidClientIP(%q) = %v, want %v", c.in, got, c.valid)
+		}
+	}
+}
+
+func TestPreSignCheckDoesNotNormalizeClientIP(t *testing.T) {
+	payload := NewNativeOrder("test", "T1", 1, "", "https://example.com/notify")
+	payload.AppId, payload.MchId = "wx123", "10000100"
+	payload.SPBillCreateIp = "[2001:db8::1]:5678"
+	if err := payload.PreSignCheck(); err == nil || !strings.Contains(err.Error(), "spbill_create_ip") {
+		t.Fatalf("err = %v, want spbill_create_ip error", err)
+	}
+	if payload.SPBillCreateIp != "[2001:db8::1]:5678" {
+		t.Fatalf("PreSignCheck modified spbill_create_ip to %q", payload.SPBillCreateIp)
+	}
+	if _, err := MarshalPayload(payload, testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+	if payload.SPBillCreateIp != "2001:db8::1" {
+		t.Fatalf("spbill_create_ip = %q, want normalized before signing", payload.SPBillCreateIp)
+	}
+	if payload.SetClientIP("1.2.3.4:5678").SPBillCreateIp != "1.2.3.4" {
+		t.Fatalf("SetClientIP = %q", payload.SPBillCreateIp)
+	}
+}
+
+func TestToParamsTotalFeeBoundary(t *testing.T) {
+	payload := testOrder(TradeTypeNative)
+	payload.ProductID = "P1"
+	payload.TotalFee = math.MaxInt32
+	if err := payload.PreSignCheck(); err != nil {
+		t.Fatalf("total_fee = MaxInt32: %v", err)
+	}
+	pm, err := toParams(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(pm["total_fee"]); got != "2147483647" {
+		t.Fatalf("total_fee = %s, want 2147483647", got)
+	}
+	if math.MaxInt > math.MaxInt32 {
+		fee := int64(math.MaxInt32)
+		payload.TotalFee = int(fee + 1)
+		if err := payload.PreSignCheck(); err == nil || !strings.Contains(err.Error(), "total_fee") {
+			t.Fatalf("total_fee = MaxInt32+1: err = %v, want total_fee error", err)
+		}
+	}
+}
+
+func TestToParamsLargeTotalFeeNotExponent(t *testing.T) {
+	payload := testOrder(TradeTypeNative)
+	payload.TotalFee = 1000000
+	pm, err := toParams(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canonical := CanonicalString(pm); !strings.Contains(canonical, "&total_fee=1000000&") {
+		t.Fatalf("canonical string = %s, want total_fee=1000000", canonical)
+	}
+	want := Sign(map[string]interface{}{
+		"appid": payload.AppId, "mch_id": payload.MchId, "nonce_str": payload.NonceStr, "body": payload.Body,
+		"out_trade_no": payload.OutTradeNo, "total_fee": "1000000", "spbill_create_ip": payload.SPBillCreateIp,
+		"notify_url": payload.NotifyURL, "trade_type": payload.TradeType,
+	}, testSecretKey)
+	if got := Sign(pm, testSecretKey); got != want {
+		t.Fatalf("sign = %s, want %s", got, want)
+	}
+}
+
+func TestCheckSecretKey(t *testing.T) {
+	for _, sk := range []string{"", strings.Repeat("a", 31), strings.Repeat("a", 33)} {
+		if err := CheckSecretKey(sk); err == nil {
+			t.Errorf("secret key of %d characters accepted", len(sk))
+		}
+	}
+	if err := CheckSecretKey(testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failTransport 发出请求即失败, 用于确认参数错误在发送请求前返回
+type failTransport struct {
+	t *testing.T
+}
+
+func (this failTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	this.t.Errorf("unexpected request to %s", r.URL)
+	return nil, errors.New("unexpected request")
+}
+
+func TestInvalidSecretKeyFailsBeforeRequest(t *testing.T) {
+	defer func(transport http.RoundTripper) { http.DefaultTransport = transport }(http.DefaultTransport)
+	http.DefaultTransport = failTransport{t}
+	c := &http.Client{Transport: failTransport{t}}
+	ctx := context.Background()
+	for _, sk := range []string{"", strings.Repeat("a", 31), strings.Repeat("a", 33)} {
+		order := testOrder(TradeTypeNative)
+		calls := map[string]error{}
+		_, calls["UnifiedOrderWithClient"] = UnifiedOrderWithClient(ctx, c, order, sk)
+		_, calls["OrderQueryWithContext"] = OrderQueryWithContext(ctx, testOrderQueryPayload(), sk)
+		_, calls["RefundWithClient"] = RefundWithClient(ctx, c, testRefundPayload(), sk)
+		_, calls["ProfitSharingFinishWithClient"] = ProfitSharingFinishWithClient(ctx, c, "4200000001", "P1", "done", "wx123", "10000100", sk)
+		_, calls["GetPublicKeyWithClient"] = GetPublicKeyWithClient(ctx, c, "10000100", sk)
+		_, calls["DoRawWithClient"] = DoRawWithClient(ctx, c, OrderQueryURL, map[string]interface{}{"mch_id": "10000100"}, sk)
+		for name, err := range calls {
+			if err == nil || !strings.Contains(err.Error(), "API secret key") {
+				t.Errorf("%s with %d-character key: err = %v, want secret key error", name, len(sk), err)
+			}
+		}
+	}
+}
+
+func TestParseChinaTime(t *testing.T) {
+	// 北京时间无夏令时, 冬季与夏季均为+08:00
+	for s, want := range map[string]time.Time{
+		"20140903131540": time.Date(2014, 9, 3, 5, 15, 40, 0, time.UTC),
+		"20150115000000": time.Date(2015, 1, 14, 16, 0, 0, 0, time.UTC),
+		"20160701235959": time.Date(2016, 7, 1, 15, 59, 59, 0, time.UTC),
+	} {
+		got, err := ParseChinaTime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("ParseChinaTime(%s) = %v, want %v", s, got.UTC(), want)
+		}
+		if _, offset := got.Zone(); offset != 8*60*60 {
+			t.Errorf("ParseChinaTime(%s) offset = %d, want +08:00", s, offset)
+		}
+	}
+	for _, s := range []string{"", "2014-09-03 13:15:40", "201409031315", "20141303131540", "2014090313154x"} {
+		if _, err := ParseChinaTime(s); err == nil {
+			t.Errorf("ParseChinaTime(%q): expected error", s)
+		}
+	}
+}
+
+func TestPayNotifyPaidAt(t *testing.T) {
+	notify, err := ParseNotify([]byte(samplePayNotifyXML()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paidAt, err := notify.PaidAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2014, 9, 3, 13, 15, 40, 0, time.FixedZone("", 8*60*60)); !paidAt.Equal(want) {
+		t.Fatalf("PaidAt = %v, want %v", paidAt, want)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+	代金券/立减优惠
+*/
+
+package weixin
+
+import (
+	"regexp"
+	"strconv"
+)
+
+const (
+	CouponTypeCash   string = "CASH"    // 充值代金券
+	CouponTypeNoCash string = "NO_CASH" // 非充值优惠券
+)
+
+var goodsTagPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+type Coupon struct {
+	CouponID   string // 代金券ID
+	CouponType string // 代金券类型
+	CouponFee  int    // 单个代金券支付金额(分)
+}
+
+type CouponRefund struct {
+	CouponRefundID  string // 退款代金券ID
+	CouponType      string // 代金券类型
+	CouponRefundFee int    // 单个代金券退款金额(分)
+}
+
+// ValidGoodsTag 订单优惠标记, 与代金券批次配置的goods_tag一致, 仅限字母、数字、_、-, 最长32位
+func ValidGoodsTag(goodsTag string) bool {
+	return goodsTagPattern.MatchString(goodsTag)
+}
+
+// parseCoupons 解析coupon_count及coupon_id_$n/coupon_type_$n/coupon_fee_$n
+func parseCoupons(body []byte) (coupons []Coupon, err error) {
+	params, err := XMLToMap(body)
+	if err != nil {
+		return
+	}
+	count, _ := strconv.Atoi(params["coupon_count"])
+	for n := 0; n < count; n++ {
+		idx := strconv.Itoa(n)
+		fee, _ := strconv.Atoi(params["coupon_fee_"+idx])
+		coupons = append(coupons, Coupon{
+			CouponID:   params["coupon_id_"+idx],
+			CouponType: params["coupon_type_"+idx],
+			CouponFee:  fee,
+		})
+	}
+	return
+}
+
+// parseCouponRefunds 解析coupon_refund_count及coupon_refund_id/coupon_type/coupon_refund_fee,
+// 申请退款的下标为_$n, 查询退款第n笔退款的下标为_$n_$m, 此时suffix为"_$n"
+func parseCouponRefunds(params map[string]string, suffix string) (coupons []CouponRefund) {
+	count, _ := strconv.Atoi(params["coupon_refund_count"+suffix])
+	for m := 0; m < count; m++ {
+		idx := suffix + "_" + strconv.Itoa(m)
+		fee, _ := strconv.Atoi(params["coupon_refund_fee"+idx])
+		coupons = append(coupons, CouponRefund{
+			CouponRefundID:  params["coupon_refund_id"+idx],
+			CouponType:      params["coupon_type"+idx],
+			CouponRefundFee: fee,
+		})
+	}
+	return
+}
@@ -0,0 +1,55 @@
+/*
+	单品优惠商品详情(detail)
+*/
+
+package weixin
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+type GoodsDetail struct {
+	GoodsID      string `json:"goods_id"`                 // R. 商品编码
+	WxpayGoodsID string `json:"wxpay_goods_id,omitempty"` // O. 微信支付定义的统一商品编号
+	GoodsName    string `json:"goods_name,omitempty"`     // O. 商品名称
+	Quantity     int    `json:"quantity"`                 // R. 商品数量
+	Price        int    `json:"price"`                    // R. 商品单价(分)
+}
+
+type discountDetail struct {
+	CostPrice   int           `json:"cost_price,omitempty"`
+	ReceiptID   string        `json:"receipt_id,omitempty"`
+	GoodsDetail []GoodsDetail `json:"goods_detail"`
+}
+
+// NewDiscountDetail 生成单品优惠的detail字段JSON, costPrice为整张小票的订单原价(分), 可为0;
+// costPrice非0时商品总价(单价*数量)不得超过costPrice
+func NewDiscountDetail(costPrice int, receiptID string, goods []GoodsDetail) (detail string, err error) {
+	if len(goods) == 0 {
+		err = errors.New("Missing required parameters: goods_detail")
+		return
+	}
+	sum := 0
+	for _, g := range goods {
+		if g.GoodsID == "" || g.Quantity <= 0 || g.Price < 0 {
+			err = errors.New("Invalid goods_detail: goods_id, quantity and price are required")
+			return
+		}
+		sum += g.Price * g.Quantity
+	}
+	if costPrice < 0 || (costPrice > 0 && sum > costPrice) {
+		err = errors.New("Invalid cost_price: must not be less than the total price of goods")
+		return
+	}
+	bs, err := json.Marshal(discountDetail{
+		CostPrice:   costPrice,
+		ReceiptID:   receiptID,
+		GoodsDetail: goods,
+	})
+	if err != nil {
+		return
+	}
+	detail = string(bs)
+	return
+}
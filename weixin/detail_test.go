@@ -0,0 +1,38 @@
+package weixin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestNewDiscountDetailGolden(t *testing.T) {
+	detail, err := NewDiscountDetail(608800, "wx123", []GoodsDetail{
+		{GoodsID: "商品编码", WxpayGoodsID: "1001", GoodsName: "iPhone6s 16G", Quantity: 1, Price: 528800},
+		{GoodsID: "1002", GoodsName: "保护壳", Quantity: 2, Price: 40000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := ioutil.ReadFile("testdata/discount_detail.golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(bytes.TrimSpace(golden)); detail != want {
+		t.Fatalf("detail =\n%s\nwant\n%s", detail, want)
+	}
+}
+
+func TestNewDiscountDetailRejectsCostPriceBelowGoods(t *testing.T) {
+	goods := []GoodsDetail{{GoodsID: "1002", Quantity: 2, Price: 40000}}
+	if _, err := NewDiscountDetail(79999, "", goods); err == nil {
+		t.Fatal("expected error when goods total exceeds cost_price")
+	}
+	detail, err := NewDiscountDetail(0, "", goods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail != `{"goods_detail":[{"goods_id":"1002","quantity":2,"price":40000}]}` {
+		t.Fatalf("detail = %s", detail)
+	}
+}
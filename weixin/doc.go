@@ -0,0 +1,16 @@
+/*
+Package weixin 微信支付API
+
+所有发起网络请求的函数均提供XxxWithContext(ctx, ...)版本, 用于控制超时及取消;
+不带ctx的版本等价于使用context.Background()调用对应的WithContext版本.
+单次调用的超时通过context.WithTimeout设置, 与外部传入ctx的截止时间取较早者:
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	resp, err := weixin.OrderQueryWithContext(ctx, payload, secretKey)
+
+请求XML中的元素顺序是确定的: 结构体字段按声明顺序输出, 空值字段省略,
+UnifiedOrderPayload.ExtraParams按参数名排序追加在结构体字段之后.
+同一payload多次序列化结果相同(随机字符串及签名除外), 可用于快照比对.
+*/
+package weixin
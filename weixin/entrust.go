@@ -0,0 +1,33 @@
+/*
+	委托代扣签约
+*/
+
+package weixin
+
+import (
+	"net/url"
+)
+
+type EntrustResult struct {
+	ContractId   string // 委托代扣协议ID
+	ContractCode string // 签约协议号
+	OpenID       string // 用户标识
+}
+
+// VerifyEntrustReturn 校验签约完成后回跳地址携带的参数签名, 不符时返回ErrSignatureMismatch
+func VerifyEntrustReturn(query url.Values, secretKey string) (result *EntrustResult, err error) {
+	params := make(map[string]interface{}, len(query))
+	for k := range query {
+		params[k] = query.Get(k)
+	}
+	if !VerifyNotifySign(params, query.Get("sign"), secretKey) {
+		err = ErrSignatureMismatch
+		return
+	}
+	result = &EntrustResult{
+		ContractId:   query.Get("contract_id"),
+		ContractCode: query.Get("contract_code"),
+		OpenID:       query.Get("openid"),
+	}
+	return
+}
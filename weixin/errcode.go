@@ -0,0 +1,80 @@
+/*
+	业务错误码分类
+*/
+
+package weixin
+
+import (
+	"net/http"
+)
+
+type ErrorClass int
+
+const (
+	Permanent  ErrorClass = iota // 不可重试, 需修正请求或人工处理
+	Retriable                    // 微信侧临时错误, 可使用相同参数重试
+	UserAction                   // 需用户操作(如输入密码、更换支付方式)
+)
+
+// ErrCodeFrequencyLimited 频率限制, 属于Retriable, 重试前需等待更长时间(见RateLimitBackoff)
+const ErrCodeFrequencyLimited string = "FREQUENCY_LIMITED"
+
+var errCodeClasses = map[string]ErrorClass{
+	"SYSTEMERROR":           Retriable,
+	"BANKERROR":             Retriable,
+	"BIZERR_NEED_RETRY":     Retriable,
+	ErrCodeFrequencyLimited: Retriable,
+	"USERPAYING":            UserAction,
+	"NOTENOUGH":             UserAction,
+	"AUTHCODEEXPIRE":        UserAction,
+	"AUTH_CODE_INVALID":     UserAction,
+	"AUTH_CODE_ERROR":       UserAction,
+	"BUYER_MISMATCH":        UserAction,
+	"NOTSUPORTCARD":         UserAction,
+	"ORDERPAID":             Permanent,
+	"OUT_TRADE_NO_USED":     Permanent,
+	"ORDERCLOSED":           Permanent,
+	"ORDERREVERSED":         Permanent,
+	"ORDERNOTEXIST":         Permanent,
+	"NOAUTH":                Permanent,
+	"APPID_NOT_EXIST":       Permanent,
+	"MCHID_NOT_EXIST":       Permanent,
+	"APPID_MCHID_NOT_MATCH": Permanent,
+	"LACK_PARAMS":           Permanent,
+	"SIGNERROR":             Permanent,
+	"XML_FORMAT_ERROR":      Permanent,
+	"NOT_UTF8":              Permanent,
+	"POST_DATA_EMPTY":       Permanent,
+	"REQUIRE_POST_METHOD":   Permanent,
+	"INVALID_REQUEST":       Permanent,
+	"PARAM_ERROR":           Permanent,
+}
+
+// ErrCodeClass 按微信错误码表对err_code分类, 未收录的错误码视为Permanent
+func ErrCodeClass(code string) ErrorClass {
+	if class, ok := errCodeClasses[code]; ok {
+		return class
+	}
+	return Permanent
+}
+
+// WxPayError 微信返回通信或业务失败时的错误, Headers为响应头, 可提供给微信支付客服排查
+type WxPayError struct {
+	ReturnCode string
+	ReturnMsg  string
+	ErrCode    string
+	ErrCodeDes string
+	Headers    http.Header
+}
+
+func (this *WxPayError) Error() string {
+	if this.ErrCodeDes != "" {
+		return this.ErrCodeDes
+	}
+	return this.ReturnMsg
+}
+
+// Class 按ErrCodeClass对err_code分类
+func (this *WxPayError) Class() ErrorClass {
+	return ErrCodeClass(this.ErrCode)
+}
@@ -0,0 +1,144 @@
+/*
+	资金账单解析
+*/
+
+package weixin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	AccountTypeBasic     string = "Basic"     // 基本账户
+	AccountTypeOperation string = "Operation" // 运营账户
+	AccountTypeFees      string = "Fees"      // 手续费账户
+)
+
+type FundFlow struct {
+	AccountType string          // 资金账户类型
+	Rows        []FundFlowRow   // 资金流水明细
+	Summary     FundFlowSummary // 汇总
+}
+
+type FundFlowRow struct {
+	BillingTime      string // 记账时间
+	BizTransactionId string // 微信支付业务单号
+	FundFlowId       string // 资金流水单号
+	BizName          string // 业务名称
+	BizType          string // 业务类型
+	FinancialType    string // 收支类型
+	Amount           int    // 收支金额(分)
+	Balance          int    // 账户结余(分)
+	Applicant        string // 资金变更提交申请人
+	Memo             string // 备注
+	BizVoucherId     string // 业务凭证号
+}
+
+type FundFlowSummary struct {
+	TotalCount    int // 资金流水总笔数
+	IncomeCount   int // 收入笔数
+	IncomeAmount  int // 收入金额(分)
+	ExpenseCount  int // 支出笔数
+	ExpenseAmount int // 支出金额(分)
+}
+
+// ParseFundFlow 解析下载的资金账单, 金额统一转换为分.
+// 下载资金账单文档(https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_18)中
+// Basic/Operation/Fees三种账户只列出一套11列的明细格式, 因此共用FundFlowRow
+func ParseFundFlow(data []byte, accountType string) (fundFlow *FundFlow, err error) {
+	switch accountType {
+	case AccountTypeBasic, AccountTypeOperation, AccountTypeFees:
+	default:
+		err = errors.New("Invalid account_type: " + accountType)
+		return
+	}
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return
+	}
+	if len(records) < 3 {
+		err = errors.New("Invalid fund flow bill: too few lines")
+		return
+	}
+	fundFlow = &FundFlow{AccountType: accountType}
+	// 首行为明细表头, 末两行为汇总表头及汇总数据
+	for _, record := range records[1 : len(records)-2] {
+		if len(record) < 11 {
+			err = errors.New("Invalid fund flow bill row: " + strings.Join(record, ","))
+			return
+		}
+		row := FundFlowRow{
+			BillingTime:      billField(record[0]),
+			BizTransactionId: billField(record[1]),
+			FundFlowId:       billField(record[2]),
+			BizName:          billField(record[3]),
+			BizType:          billField(record[4]),
+			FinancialType:    billField(record[5]),
+			Applicant:        billField(record[8]),
+			Memo:             billField(record[9]),
+			BizVoucherId:     billField(record[10]),
+		}
+		if row.Amount, err = yuanToFen(billField(record[6])); err != nil {
+			return
+		}
+		if row.Balance, err = yuanToFen(billField(record[7])); err != nil {
+			return
+		}
+		fundFlow.Rows = append(fundFlow.Rows, row)
+	}
+	summary := records[len(records)-1]
+	if len(summary) < 5 {
+		err = errors.New("Invalid fund flow bill summary: " + strings.Join(summary, ","))
+		return
+	}
+	s := &fundFlow.Summary
+	if s.TotalCount, err = strconv.Atoi(billField(summary[0])); err != nil {
+		return
+	}
+	if s.IncomeCount, err = strconv.Atoi(billField(summary[1])); err != nil {
+		return
+	}
+	if s.IncomeAmount, err = yuanToFen(billField(summary[2])); err != nil {
+		return
+	}
+	if s.ExpenseCount, err = strconv.Atoi(billField(summary[3])); err != nil {
+		return
+	}
+	s.ExpenseAmount, err = yuanToFen(billField(summary[4]))
+	return
+}
+
+// billField 去除账单字段前的`及空白
+func billField(s string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "`"))
+}
+
+// yuanToFen 将"12.34"格式的元金额转换为分
+func yuanToFen(s string) (fen int, err error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	parts := strings.SplitN(s, ".", 2)
+	yuan, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return
+	}
+	cents := 0
+	if len(parts) == 2 {
+		frac := (parts[1] + "00")[:2]
+		if cents, err = strconv.Atoi(frac); err != nil {
+			return
+		}
+	}
+	fen = yuan*100 + cents
+	if neg {
+		fen = -fen
+	}
+	return
+}
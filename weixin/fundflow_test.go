@@ -0,0 +1,104 @@
+package weixin
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseFundFlow(t *testing.T) {
+	cases := []struct {
+		file        string
+		accountType string
+		rows        []FundFlowRow
+		summary     FundFlowSummary
+	}{
+		{"testdata/fundflow_basic.csv", AccountTypeBasic, []FundFlowRow{
+			{"2018-02-01 04:21:23", "4200000053201801316414470426", "4200000053201801316414470426", "交易", "交易", "收入", 10001, 110001, "system", "缺省", "4200000053201801316414470426"},
+			{"2018-02-01 15:43:56", "50000305742018020103387128253", "1900000109201802011505438251", "退款", "退款", "支出", 50, 109951, "system", "缺省", "REF4200000068201801293084726067"},
+		}, FundFlowSummary{2, 1, 10001, 1, 50}},
+		{"testdata/fundflow_operation.csv", AccountTypeOperation, []FundFlowRow{
+			{"2018-02-02 10:00:00", "3008450740201411110007820472", "1900000109201802020000000001", "分账", "分账", "收入", 3000, 3000, "system", "分账到运营账户", "P20180202001"},
+		}, FundFlowSummary{1, 1, 3000, 0, 0}},
+		{"testdata/fundflow_fees.csv", AccountTypeFees, []FundFlowRow{
+			{"2018-02-01 04:21:23", "4200000053201801316414470426", "1900000109201802010000000011", "扣除交易手续费", "手续费", "支出", 60, -60, "system", "缺省", "4200000053201801316414470426"},
+			{"2018-02-03 09:00:00", "", "1900000109201802030000000012", "充值", "充值", "收入", 1000, 940, "10000100", "手续费账户充值", ""},
+		}, FundFlowSummary{2, 1, 1000, 1, 60}},
+	}
+	for _, c := range cases {
+		data, err := ioutil.ReadFile(c.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fundFlow, err := ParseFundFlow(data, c.accountType)
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if fundFlow.AccountType != c.accountType {
+			t.Errorf("%s: account type = %s", c.file, fundFlow.AccountType)
+		}
+		if len(fundFlow.Rows) != len(c.rows) {
+			t.Fatalf("%s: rows = %+v", c.file, fundFlow.Rows)
+		}
+		for i, row := range fundFlow.Rows {
+			if row != c.rows[i] {
+				t.Errorf("%s: row %d =\n%+v\nwant\n%+v", c.file, i, row, c.rows[i])
+			}
+		}
+		if fundFlow.Summary != c.summary {
+			t.Errorf("%s: summary = %+v, want %+v", c.file, fundFlow.Summary, c.summary)
+		}
+	}
+}
+
+func TestParseFundFlowInvalid(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/fundflow_basic.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseFundFlow(data, "basic"); err == nil {
+		t.Error("lowercase account type accepted")
+	}
+	if _, err = ParseFundFlow([]byte("记账时间\n资金流水总笔数\n"), AccountTypeBasic); err == nil {
+		t.Error("bill without summary row accepted")
+	}
+	short := "h\n`2018-02-01 04:21:23,`1,`2\n资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\n`1,`1,`0.01,`0,`0.00\n"
+	if _, err = ParseFundFlow([]byte(short), AccountTypeBasic); err == nil {
+		t.Error("short row accepted")
+	}
+}
+
+func TestBillField(t *testing.T) {
+	for in, want := range map[string]string{
+		"`4200000053201801316414470426": "4200000053201801316414470426",
+		" `0.01 ":                       "0.01",
+		"`":                             "",
+		"system":                        "system",
+		"``x":                           "`x",
+	} {
+		if got := billField(in); got != want {
+			t.Errorf("billField(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestYuanToFen(t *testing.T) {
+	for in, want := range map[string]int{
+		"12.34":   1234,
+		"0.01":    1,
+		"0.5":     50,
+		"100":     10000,
+		"-0.50":   -50,
+		"-12.30":  -1230,
+		"+1.00":   100,
+		"1000.01": 100001,
+	} {
+		if got, err := yuanToFen(in); err != nil || got != want {
+			t.Errorf("yuanToFen(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "1.ab", "1,000.00"} {
+		if _, err := yuanToFen(in); err == nil {
+			t.Errorf("yuanToFen(%q) accepted", in)
+		}
+	}
+}
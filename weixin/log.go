@@ -0,0 +1,138 @@
+package weixin
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Logger 调试日志接口, *log.Logger即满足
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// DebugLogger 请求/响应调试日志, 默认nil不输出. 日志含openid、交易单号等原始报文, 建议以NewRedactLogger包装:
+//
+//	weixin.DebugLogger = weixin.NewRedactLogger(log.New(os.Stderr, "", 0))
+var DebugLogger Logger
+
+// DebugSignString 是否输出待签名字符串(stringA), 密钥以***代替, 默认关闭
+var DebugSignString = false
+
+// DebugRequestXML 是否以缩进格式输出请求XML, 仅影响日志, 不影响实际发送内容
+var DebugRequestXML = false
+
+// DefaultRedactFields 默认脱敏字段
+var DefaultRedactFields = []string{"openid", "auth_code", "enc_bank_no", "sign"}
+
+func debugln(v ...interface{}) {
+	if DebugLogger != nil {
+		DebugLogger.Println(v...)
+	}
+}
+
+func debugSignString(str string) {
+	if DebugSignString {
+		debugln("Prepare signature:", str+"&key=***")
+	}
+}
+
+func debugRequestXML(body []byte) {
+	if !DebugRequestXML {
+		return
+	}
+	indented, err := indentXML(body)
+	if err != nil {
+		debugln("Request:", string(body))
+		return
+	}
+	debugln("Request:\n" + string(indented))
+}
+
+func indentXML(body []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err = encoder.EncodeToken(token); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RedactLogger 对敏感字段脱敏后再输出日志
+type RedactLogger struct {
+	Logger Logger
+	Fields []string
+}
+
+// NewRedactLogger 包装logger, 未指定fields时使用DefaultRedactFields
+func NewRedactLogger(logger Logger, fields ...string) *RedactLogger {
+	if len(fields) == 0 {
+		fields = DefaultRedactFields
+	}
+	return &RedactLogger{Logger: logger, Fields: fields}
+}
+
+func (this *RedactLogger) Println(v ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+	this.Logger.Println(Redact(msg, this.Fields...))
+}
+
+// 脱敏匹配模式, 在包初始化时编译一次, Redact按字段名筛选匹配结果
+var (
+	redactXMLPattern  = regexp.MustCompile(`<([\w.-]+)>([^<]*|<!\[CDATA\[(?s:.*?)\]\]>)</([\w.-]+)>`)
+	redactJSONPattern = regexp.MustCompile(`"([\w.-]+)"(\s*:\s*)"[^"]*"`)
+	redactKVPattern   = regexp.MustCompile(`(^|[&\s])([\w.-]+)=[^&\s]*`)
+)
+
+// Redact 将XML、JSON及k=v&k=v格式中指定字段的值替换为***
+func Redact(s string, fields ...string) string {
+	redact := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		redact[field] = true
+	}
+	s = replaceSubmatch(redactXMLPattern, s, func(m []string) string {
+		if m[1] != m[3] || !redact[m[1]] {
+			return m[0]
+		}
+		return "<" + m[1] + ">***</" + m[1] + ">"
+	})
+	s = replaceSubmatch(redactJSONPattern, s, func(m []string) string {
+		if !redact[m[1]] {
+			return m[0]
+		}
+		return `"` + m[1] + `"` + m[2] + `"***"`
+	})
+	return replaceSubmatch(redactKVPattern, s, func(m []string) string {
+		if !redact[m[2]] {
+			return m[0]
+		}
+		return m[1] + m[2] + "=***"
+	})
+}
+
+// replaceSubmatch 以repl(子匹配)替换s中re的每个匹配
+func replaceSubmatch(re *regexp.Regexp, s string, repl func(m []string) string) string {
+	return re.ReplaceAllStringFunc(s, func(match string) string {
+		return repl(re.FindStringSubmatch(match))
+	})
+}
@@ -0,0 +1,31 @@
+package weixin
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{
+			"<xml><openid>oUpF8uMuAJO_M2pxb1Q9zNjWeS6o</openid><out_trade_no>T1</out_trade_no></xml>",
+			"<xml><openid>***</openid><out_trade_no>T1</out_trade_no></xml>",
+		},
+		{
+			"<xml><auth_code><![CDATA[134<567]]></auth_code><sign_type>MD5</sign_type></xml>",
+			"<xml><auth_code>***</auth_code><sign_type>MD5</sign_type></xml>",
+		},
+		{
+			`{"openid": "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", "total_fee": "1"}`,
+			`{"openid": "***", "total_fee": "1"}`,
+		},
+		{
+			"appid=wx123&openid=oUpF8u&sign=ABC total_fee=1",
+			"appid=wx123&openid=***&sign=*** total_fee=1",
+		},
+	}
+	for _, c := range cases {
+		if got := Redact(c.in, DefaultRedactFields...); got != c.want {
+			t.Errorf("Redact(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+	付款码支付API
+*/
+
+package weixin
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+)
+
+const (
+	MicroPayURL string = "https://api.mch.weixin.qq.com/pay/micropay"
+)
+
+type MicroPayPayload struct {
+	XMLName        xml.Name `xml:"xml" json:"-"`
+	AppId          string   `json:"appid,omitempty" xml:"appid,omitempty"`                       // R. 应用ID
+	MchId          string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                     // R. 商户号
+	DeviceInfo     string   `json:"device_info,omitempty" xml:"device_info,omitempty"`           // O. 设备号
+	NonceStr       string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`               // R. 随机字符串
+	Sign           string   `json:"sign,omitempty" xml:"sign,omitempty"`                         // R. 签名
+	SignType       string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`               // O. 签名类型,默认MD5
+	Body           string   `json:"body,omitempty" xml:"body,omitempty"`                         // R. 商品描述
+	Detail         string   `json:"detail,omitempty" xml:"detail,omitempty"`                     // O. 单品优惠详情
+	Attach         string   `json:"attach,omitempty" xml:"attach,omitempty"`                     // O. 附加数据
+	OutTradeNo     string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`         // R. 商户订单号
+	TotalFee       int      `json:"total_fee,omitempty" xml:"total_fee,omitempty"`               // R. 订单金额(分), 零值省略, 由PreSignCheck保证必传
+	FeeType        string   `json:"fee_type,omitempty" xml:"fee_type,omitempty"`                 // O. 货币类型
+	SPBillCreateIp string   `json:"spbill_create_ip,omitempty" xml:"spbill_create_ip,omitempty"` // R. 终端IP
+	GoodsTag       string   `json:"goods_tag,omitempty" xml:"goods_tag,omitempty"`               // O. 订单优惠标记
+	LimitPay       string   `json:"limit_pay,omitempty" xml:"limit_pay,omitempty"`               // O. 指定支付方式
+	TimeStart      string   `json:"time_start,omitempty" xml:"time_start,omitempty"`             // O. 交易起始时间
+	TimeExpire     string   `json:"time_expire,omitempty" xml:"time_expire,omitempty"`           // O. 交易结束时间
+	Receipt        string   `json:"receipt,omitempty" xml:"receipt,omitempty"`                   // O. 电子发票入口开放标识
+	AuthCode       string   `json:"auth_code,omitempty" xml:"auth_code,omitempty"`               // R. 付款码
+	SceneInfo      string   `json:"scene_info,omitempty" xml:"scene_info,omitempty"`             // O. 场景信息
+}
+
+func (this *MicroPayPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+// PreSignCheck 检查必传参数, 不修改payload. spbill_create_ip须为不带端口的IP地址,
+// MicroPay在检查前自动经NormalizeClientIP转换
+func (this *MicroPayPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("nonce_str", this.NonceStr != "")
+	v.require("body", this.Body != "")
+	v.require("out_trade_no", this.OutTradeNo != "")
+	v.require("total_fee", this.TotalFee != 0)
+	v.check("total_fee", this.TotalFee >= 0 && this.TotalFee <= MaxTotalFee)
+	v.require("spbill_create_ip", this.SPBillCreateIp != "")
+	v.check("spbill_create_ip", this.SPBillCreateIp == "" || ValidClientIP(this.SPBillCreateIp))
+	v.require("auth_code", this.AuthCode != "")
+	return v.err()
+}
+
+type MicroPayResp struct {
+	ReturnCode         string `xml:"return_code"`
+	ReturnMsg          string `xml:"return_msg"`
+	AppId              string `xml:"appid"`
+	MchId              string `xml:"mch_id"`
+	DeviceInfo         string `xml:"device_info"`
+	NonceStr           string `xml:"nonce_str"`
+	Sign               string `xml:"sign"`
+	ResultCode         string `xml:"result_code"`
+	ErrCode            string `xml:"err_code"`
+	ErrCodeDes         string `xml:"err_code_des"`
+	OpenID             string `xml:"openid"`
+	IsSubscribe        string `xml:"is_subscribe"`
+	TradeType          string `xml:"trade_type"`
+	BankType           string `xml:"bank_type"`
+	FeeType            string `xml:"fee_type"`
+	TotalFee           int    `xml:"total_fee"`
+	SettlementTotalFee int    `xml:"settlement_total_fee"`
+	CouponFee          int    `xml:"coupon_fee"`
+	CouponCount        int    `xml:"coupon_count"`
+	CashFeeType        string `xml:"cash_fee_type"`
+	CashFee            int    `xml:"cash_fee"`
+	TransactionId      string `xml:"transaction_id"`
+	OutTradeNo         string `xml:"out_trade_no"`
+	Attach             string `xml:"attach"`
+	TimeEnd            string `xml:"time_end"`
+	PromotionDetail    string `xml:"promotion_detail"` // 优惠详情(JSON), 见Promotions
+
+	Coupons    []Coupon          `xml:"-"` // 代金券使用明细(coupon_*_$n)
+	Promotions []PromotionDetail `xml:"-"`
+}
+
+func (this *MicroPayResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func (this *MicroPayResp) IsSubscribed() bool {
+	return this.IsSubscribe == "Y"
+}
+
+// IsUserPaying 用户支付中(需输入密码), 应轮询订单查询确认结果
+func (this *MicroPayResp) IsUserPaying() bool {
+	return this.ErrCode == TradeStateUserPaying
+}
+
+func MicroPay(payload *MicroPayPayload, secretKey string) (response MicroPayResp, err error) {
+	return MicroPayWithContext(context.Background(), payload, secretKey)
+}
+
+func MicroPayWithContext(ctx context.Context, payload *MicroPayPayload, secretKey string) (response MicroPayResp, err error) {
+	return MicroPayWithClient(ctx, &http.Client{Timeout: DefaultTimeout}, payload, secretKey)
+}
+
+// MicroPayWithClient 使用调用方提供的HTTP客户端付款码支付
+func MicroPayWithClient(ctx context.Context, c *http.Client, payload *MicroPayPayload, secretKey string) (response MicroPayResp, err error) {
+	payload.SPBillCreateIp = NormalizeClientIP(payload.SPBillCreateIp)
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	body, err1 := doXMLRequest(ctx, c, MicroPayURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	if response.Coupons, err = parseCoupons(body); err != nil {
+		return
+	}
+	if response.Promotions, err = ParsePromotionDetail(response.PromotionDetail); err != nil {
+		return
+	}
+	return
+}
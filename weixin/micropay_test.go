@@ -0,0 +1,66 @@
+package weixin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// microPayCouponRespXML 使用两张代金券并含单品优惠的付款码支付响应
+const microPayCouponRespXML = `<xml>
+  <return_code><![CDATA[SUCCESS]]></return_code>
+  <return_msg><![CDATA[OK]]></return_msg>
+  <appid><![CDATA[wx2421b1c4370ec43b]]></appid>
+  <mch_id><![CDATA[10000100]]></mch_id>
+  <nonce_str><![CDATA[o5bAKF3o2ypC8hwa]]></nonce_str>
+  <result_code><![CDATA[SUCCESS]]></result_code>
+  <openid><![CDATA[oUpF8uN95-Ptaags6E_roPHg7AG0]]></openid>
+  <is_subscribe><![CDATA[Y]]></is_subscribe>
+  <trade_type><![CDATA[MICROPAY]]></trade_type>
+  <bank_type><![CDATA[CCB_DEBIT]]></bank_type>
+  <total_fee>1000</total_fee>
+  <fee_type><![CDATA[CNY]]></fee_type>
+  <cash_fee>700</cash_fee>
+  <coupon_fee>300</coupon_fee>
+  <coupon_count>2</coupon_count>
+  <coupon_id_0><![CDATA[2000000000001]]></coupon_id_0>
+  <coupon_type_0><![CDATA[CASH]]></coupon_type_0>
+  <coupon_fee_0>200</coupon_fee_0>
+  <coupon_id_1><![CDATA[2000000000002]]></coupon_id_1>
+  <coupon_type_1><![CDATA[NO_CASH]]></coupon_type_1>
+  <coupon_fee_1>100</coupon_fee_1>
+  <transaction_id><![CDATA[1008450740201411110005820873]]></transaction_id>
+  <out_trade_no><![CDATA[1415757673]]></out_trade_no>
+  <time_end><![CDATA[20141111170043]]></time_end>
+  <promotion_detail><![CDATA[{"promotion_detail":[{"promotion_id":"2000000000001","name":"满10减2","scope":"GLOBAL","type":"COUPON","amount":200,"activity_id":"931386","wxpay_contribute":0,"merchant_contribute":200,"other_contribute":0},{"promotion_id":"2000000000002","name":"单品立减","scope":"SINGLE","type":"DISCOUNT","amount":100,"activity_id":"931387","wxpay_contribute":100,"merchant_contribute":0,"other_contribute":0,"goods_detail":[{"goods_id":"G1","quantity":1,"price":500,"discount_amount":100}]}]}]]></promotion_detail>
+</xml>`
+
+func TestMicroPayParsesCoupons(t *testing.T) {
+	server := newXMLServer(t, http.StatusOK, microPayCouponRespXML, nil)
+	payload := &MicroPayPayload{
+		AppId: "wx2421b1c4370ec43b", MchId: "10000100", NonceStr: "n1", Body: "test", OutTradeNo: "1415757673",
+		TotalFee: 1000, SPBillCreateIp: "1.2.3.4", AuthCode: "120061098828009406",
+	}
+	response, err := MicroPayWithClient(context.Background(), redirectClient(server), payload, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TotalFee != 1000 || response.CashFee != 700 || response.CouponFee != 300 || response.CouponCount != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	want := []Coupon{
+		{CouponID: "2000000000001", CouponType: CouponTypeCash, CouponFee: 200},
+		{CouponID: "2000000000002", CouponType: CouponTypeNoCash, CouponFee: 100},
+	}
+	if len(response.Coupons) != len(want) {
+		t.Fatalf("coupons = %+v, want %+v", response.Coupons, want)
+	}
+	for i := range want {
+		if response.Coupons[i] != want[i] {
+			t.Errorf("coupon %d = %+v, want %+v", i, response.Coupons[i], want[i])
+		}
+	}
+	if len(response.Promotions) != 2 || response.Promotions[1].GoodsDetail[0].DiscountAmount != 100 {
+		t.Fatalf("promotions = %+v", response.Promotions)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+	H5支付
+*/
+
+package weixin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+type h5SceneInfo struct {
+	H5Info struct {
+		Type    string `json:"type"`
+		WapURL  string `json:"wap_url"`
+		WapName string `json:"wap_name"`
+	} `json:"h5_info"`
+}
+
+// NewMWEBOrder H5支付订单, wapURL、wapName为发起支付的WAP网站URL及名称(scene_info), 仍需设置AppId、MchId、SPBillCreateIp
+func NewMWEBOrder(body, outTradeNo string, totalFee int, wapURL, wapName, notifyURL string) *UnifiedOrderPayload {
+	return newUnifiedOrder(TradeTypeMWEB, body, outTradeNo, totalFee, notifyURL).SetH5SceneInfo(wapURL, wapName)
+}
+
+// SetH5SceneInfo 设置H5支付必传的scene_info(WAP网站), 可链式调用
+func (this *UnifiedOrderPayload) SetH5SceneInfo(wapURL, wapName string) *UnifiedOrderPayload {
+	info := h5SceneInfo{}
+	info.H5Info.Type = "Wap"
+	info.H5Info.WapURL = wapURL
+	info.H5Info.WapName = wapName
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(info)
+	this.SceneInfo = strings.TrimSpace(buf.String())
+	return this
+}
+
+func (this *UnifiedOrderResp) MWEB() string {
+	if this.TradeType != TradeTypeMWEB {
+		return ""
+	}
+	return this.MwebURL
+}
+
+// AppendRedirectURL 为mweb_url追加支付完成后的回跳地址redirect_url(URL编码)
+func AppendRedirectURL(mwebURL, redirectURL string) (string, error) {
+	if redirectURL == "" {
+		return "", errors.New("Missing required parameters: redirect_url")
+	}
+	u, err := url.Parse(mwebURL)
+	if err != nil {
+		return "", err
+	}
+	if _, err = url.Parse(redirectURL); err != nil {
+		return "", err
+	}
+	sep := "?"
+	if u.RawQuery != "" {
+		sep = "&"
+	} else if strings.HasSuffix(mwebURL, "?") {
+		sep = ""
+	}
+	return mwebURL + sep + "redirect_url=" + url.QueryEscape(redirectURL), nil
+}
@@ -0,0 +1,38 @@
+package weixin
+
+import "testing"
+
+func TestAppendRedirectURL(t *testing.T) {
+	cases := []struct {
+		mwebURL string
+		want    string
+	}{
+		{"https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb", "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?redirect_url=https%3A%2F%2Fexample.com%2Fpaid%3Forder%3DT1%26a%3Db"},
+		{"https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx1&package=123", "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx1&package=123&redirect_url=https%3A%2F%2Fexample.com%2Fpaid%3Forder%3DT1%26a%3Db"},
+	}
+	for _, c := range cases {
+		got, err := AppendRedirectURL(c.mwebURL, "https://example.com/paid?order=T1&a=b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("AppendRedirectURL(%q) = %q, want %q", c.mwebURL, got, c.want)
+		}
+	}
+}
+
+func TestMWEBOrderRequiresSceneInfo(t *testing.T) {
+	payload := NewMWEBOrder("test", "T1", 1, "https://example.com/shop?a=1&b=2", "示例商城", "https://example.com/notify")
+	payload.AppId, payload.MchId, payload.SPBillCreateIp = "wx123", "10000100", "1.2.3.4"
+	if err := payload.PreSignCheck(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"h5_info":{"type":"Wap","wap_url":"https://example.com/shop?a=1&b=2","wap_name":"示例商城"}}`
+	if payload.SceneInfo != want {
+		t.Fatalf("scene_info = %s, want %s", payload.SceneInfo, want)
+	}
+	payload.SceneInfo = ""
+	if err := payload.PreSignCheck(); err == nil {
+		t.Fatal("expected MWEB order without scene_info to be rejected")
+	}
+}
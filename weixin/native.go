@@ -0,0 +1,40 @@
+/*
+	扫码支付模式一
+*/
+
+package weixin
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const BizPayURLPrefix string = "weixin://wxpay/bizpayurl"
+
+// BizPayURL 扫码支付模式一二维码链接, product_id必传且参与签名
+func BizPayURL(appId, mchId, productID, secretKey string) (string, error) {
+	v := &ValidationError{}
+	v.require("appid", appId != "")
+	v.require("mch_id", mchId != "")
+	v.require("product_id", productID != "")
+	if err := v.err(); err != nil {
+		return "", err
+	}
+	if err := CheckSecretKey(secretKey); err != nil {
+		return "", err
+	}
+	pm := map[string]interface{}{
+		"appid":      appId,
+		"mch_id":     mchId,
+		"product_id": productID,
+		"time_stamp": strconv.FormatInt(time.Now().Unix(), 10),
+		"nonce_str":  NonceStr(),
+	}
+	query := url.Values{}
+	for k, val := range pm {
+		query.Set(k, val.(string))
+	}
+	query.Set("sign", Sign(pm, secretKey))
+	return BizPayURLPrefix + "?" + query.Encode(), nil
+}
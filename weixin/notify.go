@@ -0,0 +1,154 @@
+/*
+	微信支付结果通知
+*/
+
+package weixin
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	NotifyTypePay    string = "pay"
+	NotifyTypeRefund string = "refund"
+)
+
+var ErrInvalidNotifySign = errors.New("Invalid notify signature")
+
+// ErrNotXML 通知报文不是<xml>开头, 通常为伪造或探测请求, 应答FAIL并返回400
+var ErrNotXML = errors.New("Notify body is not XML")
+
+type PayNotify struct {
+	ReturnCode         string `xml:"return_code"`
+	ReturnMsg          string `xml:"return_msg"`
+	AppId              string `xml:"appid"`
+	MchId              string `xml:"mch_id"`
+	DeviceInfo         string `xml:"device_info"`
+	NonceStr           string `xml:"nonce_str"`
+	Sign               string `xml:"sign"`
+	SignType           string `xml:"sign_type"`
+	ResultCode         string `xml:"result_code"`
+	ErrCode            string `xml:"err_code"`
+	ErrCodeDes         string `xml:"err_code_des"`
+	OpenID             string `xml:"openid"`
+	IsSubscribe        string `xml:"is_subscribe"` // 是否关注公众账号(Y/N)
+	TradeType          string `xml:"trade_type"`
+	BankType           string `xml:"bank_type"` // 付款银行
+	TotalFee           int    `xml:"total_fee"`
+	SettlementTotalFee int    `xml:"settlement_total_fee"`
+	FeeType            string `xml:"fee_type"`
+	CashFee            int    `xml:"cash_fee"`
+	CashFeeType        string `xml:"cash_fee_type"`
+	CouponFee          int    `xml:"coupon_fee"`
+	CouponCount        int    `xml:"coupon_count"`
+	TransactionId      string `xml:"transaction_id"`
+	OutTradeNo         string `xml:"out_trade_no"`
+	Attach             string `xml:"attach"`
+	TimeEnd            string `xml:"time_end"`
+	PromotionDetail    string `xml:"promotion_detail"` // 优惠详情(JSON), 解析结果见Promotions
+
+	Coupons    []Coupon          `xml:"-"` // 代金券使用明细(coupon_*_$n)
+	Promotions []PromotionDetail `xml:"-"`
+}
+
+func (this *PayNotify) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+// PaidAt 支付完成时间(time_end)
+func (this *PayNotify) PaidAt() (time.Time, error) {
+	return ParseChinaTime(this.TimeEnd)
+}
+
+func (this *PayNotify) IsSubscribed() bool {
+	return this.IsSubscribe == "Y"
+}
+
+// NotifyType 判断通知类型, 退款结果通知含加密的req_info字段
+func NotifyType(body []byte) (notifyType string, err error) {
+	params, err := XMLToMap(body)
+	if err != nil {
+		return
+	}
+	if _, ok := params["req_info"]; ok {
+		notifyType = NotifyTypeRefund
+	} else {
+		notifyType = NotifyTypePay
+	}
+	return
+}
+
+// ParseNotify 解析支付结果通知, 不校验签名. notify_url处理请使用ParseAndVerifyNotify.
+// 报文不是<xml>开头时返回ErrNotXML
+func ParseNotify(body []byte) (notify *PayNotify, err error) {
+	body = trimXMLBody(body)
+	if !bytes.HasPrefix(body, []byte("<xml")) {
+		err = ErrNotXML
+		return
+	}
+	notify = &PayNotify{}
+	if err = xml.Unmarshal(body, notify); err != nil {
+		notify = nil
+		return
+	}
+	if notify.Coupons, err = parseCoupons(body); err != nil {
+		return
+	}
+	notify.Promotions, err = ParsePromotionDetail(notify.PromotionDetail)
+	return
+}
+
+// ParseAndVerifyNotify 解析支付结果通知并按通知中的sign_type校验签名,
+// 签名不符时返回ErrInvalidNotifySign. notify_url处理推荐使用此方法
+func ParseAndVerifyNotify(body []byte, secretKey string) (notify *PayNotify, err error) {
+	if !bytes.HasPrefix(trimXMLBody(body), []byte("<xml")) {
+		err = ErrNotXML
+		return
+	}
+	params, err := XMLToMap(body)
+	if err != nil {
+		return
+	}
+	pm := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		pm[k] = v
+	}
+	if !VerifyNotifySign(pm, params["sign"], secretKey) {
+		err = ErrInvalidNotifySign
+		return
+	}
+	return ParseNotify(body)
+}
+
+// VerifyNotifySign 校验通知签名, params中的sign字段不参与签名.
+// 签名类型取自通知自身的sign_type(未携带时为MD5), 与下单时使用的签名类型无关
+func VerifyNotifySign(params map[string]interface{}, sign, secretKey string) bool {
+	pm := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if k != "sign" {
+			pm[k] = v
+		}
+	}
+	signType := ""
+	if v, ok := pm["sign_type"]; ok {
+		signType = fmt.Sprint(v)
+	}
+	expected := SignWithType(pm, secretKey, signType)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToUpper(sign))) == 1
+}
+
+// VerifyNotifySignWithKeys 依次使用候选密钥校验通知签名, 用于API密钥轮换期间
+func VerifyNotifySignWithKeys(params map[string]interface{}, sign string, keys ...string) bool {
+	for _, key := range keys {
+		if VerifyNotifySign(params, sign, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package weixin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAndVerifyNotify(t *testing.T) {
+	notify, err := ParseAndVerifyNotify([]byte(testPayNotifyXML()), testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notify.OutTradeNo != "T1" || notify.TotalFee != 100 || !notify.IsSuccess() {
+		t.Fatalf("notify = %+v", notify)
+	}
+}
+
+func TestParseAndVerifyNotifyRejectsTamperedSign(t *testing.T) {
+	body := testPayNotifyXML()
+	tampered := []string{
+		strings.Replace(body, "<total_fee>100</total_fee>", "<total_fee>1</total_fee>", 1),
+		strings.Replace(body, "<out_trade_no>T1</out_trade_no>", "<out_trade_no>T2</out_trade_no>", 1),
+		body[:strings.Index(body, "<sign>")] + "<sign>9A0A8659F005D6984697E2CA0A9CF3B7</sign></xml>",
+		body[:strings.Index(body, "<sign>")] + "</xml>",
+	}
+	for _, b := range tampered {
+		if _, err := ParseAndVerifyNotify([]byte(b), testSecretKey); err != ErrInvalidNotifySign {
+			t.Errorf("err = %v, want ErrInvalidNotifySign for %s", err, b)
+		}
+	}
+	if _, err := ParseAndVerifyNotify([]byte(body), "99999999999999999999999999999999"); err != ErrInvalidNotifySign {
+		t.Errorf("wrong key: err = %v, want ErrInvalidNotifySign", err)
+	}
+}
+
+func TestParseAndVerifyNotifyHMAC(t *testing.T) {
+	pm := map[string]interface{}{"return_code": "SUCCESS", "result_code": "SUCCESS", "out_trade_no": "T1", "sign_type": SignTypeHMACSHA256}
+	sign := SignWithType(pm, testSecretKey, SignTypeHMACSHA256)
+	body := "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><out_trade_no>T1</out_trade_no><sign_type>HMAC-SHA256</sign_type><sign>" + sign + "</sign></xml>"
+	if _, err := ParseAndVerifyNotify([]byte(body), testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseAndVerifyNotifyRejectsNonXML(t *testing.T) {
+	if _, err := ParseAndVerifyNotify([]byte(`{"return_code":"SUCCESS"}`), testSecretKey); err != ErrNotXML {
+		t.Fatalf("err = %v, want ErrNotXML", err)
+	}
+}
+
+// samplePayNotifyXML 支付结果通知文档中的示例报文. 代金券字段按字段说明使用下标形式(coupon_id_$n),
+// sign为以testSecretKey重新计算的结果(文档示例的API密钥未公开)
+func samplePayNotifyXML() string {
+	params := map[string]interface{}{
+		"appid": "wx2421b1c4370ec43b", "attach": "支付测试", "bank_type": "CFT", "fee_type": "CNY",
+		"is_subscribe": "Y", "mch_id": "10000100", "nonce_str": "5d2b6c2a8db53831f7eda20af46e531c",
+		"openid": "oUpF8uMEb4qRXf22hE3X68TekukE", "out_trade_no": "1409811653", "result_code": "SUCCESS",
+		"return_code": "SUCCESS", "time_end": "20140903131540", "total_fee": "1", "coupon_fee": "10",
+		"coupon_count": "1", "coupon_type_0": "CASH", "coupon_id_0": "10000", "coupon_fee_0": "10",
+		"trade_type": "JSAPI", "transaction_id": "1004400740201409030005092168",
+	}
+	return `<xml>
+  <appid><![CDATA[wx2421b1c4370ec43b]]></appid>
+  <attach><![CDATA[支付测试]]></attach>
+  <bank_type><![CDATA[CFT]]></bank_type>
+  <fee_type><![CDATA[CNY]]></fee_type>
+  <is_subscribe><![CDATA[Y]]></is_subscribe>
+  <mch_id><![CDATA[10000100]]></mch_id>
+  <nonce_str><![CDATA[5d2b6c2a8db53831f7eda20af46e531c]]></nonce_str>
+  <openid><![CDATA[oUpF8uMEb4qRXf22hE3X68TekukE]]></openid>
+  <out_trade_no><![CDATA[1409811653]]></out_trade_no>
+  <result_code><![CDATA[SUCCESS]]></result_code>
+  <return_code><![CDATA[SUCCESS]]></return_code>
+  <sign><![CDATA[` + Sign(params, testSecretKey) + `]]></sign>
+  <time_end><![CDATA[20140903131540]]></time_end>
+  <total_fee>1</total_fee>
+  <coupon_fee><![CDATA[10]]></coupon_fee>
+  <coupon_count><![CDATA[1]]></coupon_count>
+  <coupon_type_0><![CDATA[CASH]]></coupon_type_0>
+  <coupon_id_0><![CDATA[10000]]></coupon_id_0>
+  <coupon_fee_0>10</coupon_fee_0>
+  <trade_type><![CDATA[JSAPI]]></trade_type>
+  <transaction_id><![CDATA[1004400740201409030005092168]]></transaction_id>
+</xml>`
+}
+
+func TestParseSamplePayNotify(t *testing.T) {
+	notify, err := ParseAndVerifyNotify([]byte(samplePayNotifyXML()), testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notify.BankType != "CFT" || !notify.IsSubscribed() || notify.IsSubscribe != "Y" {
+		t.Fatalf("bank_type = %q, is_subscribe = %q", notify.BankType, notify.IsSubscribe)
+	}
+	if notify.AppId != "wx2421b1c4370ec43b" || notify.Attach != "支付测试" || notify.OpenID != "oUpF8uMEb4qRXf22hE3X68TekukE" ||
+		notify.OutTradeNo != "1409811653" || notify.TransactionId != "1004400740201409030005092168" ||
+		notify.TotalFee != 1 || notify.FeeType != "CNY" || notify.TradeType != TradeTypeJSAPI || !notify.IsSuccess() {
+		t.Fatalf("notify = %+v", notify)
+	}
+	if notify.CouponFee != 10 || len(notify.Coupons) != 1 || notify.Coupons[0] != (Coupon{CouponID: "10000", CouponType: CouponTypeCash, CouponFee: 10}) {
+		t.Fatalf("coupons = %+v", notify.Coupons)
+	}
+	notify.IsSubscribe = "N"
+	if notify.IsSubscribed() {
+		t.Fatal("is_subscribe N reported as subscribed")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+	支付及退款结果通知处理
+*/
+
+package weixin
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrStopRetry 回调返回此错误(或包装此错误)时应答SUCCESS, 微信不再重发通知, 用于无法通过重试解决的业务失败
+var ErrStopRetry = errors.New("Stop notify retry")
+
+// ErrNoNotifyCallback 收到的通知类型未设置对应回调
+var ErrNoNotifyCallback = errors.New("No callback set for this notify type")
+
+// maxNotifyBody 通知报文最大长度
+const maxNotifyBody = 1 << 20
+
+type notifyAck struct {
+	XMLName    xml.Name `xml:"xml"`
+	ReturnCode string   `xml:"return_code"`
+	ReturnMsg  string   `xml:"return_msg"`
+}
+
+// NotifyHandler 支付及退款结果通知的http.Handler, 按NotifyType分发:
+// 支付结果通知校验签名后调用OnPay, 退款结果通知解密req_info后调用OnRefund.
+// 回调返回nil或ErrStopRetry时应答SUCCESS; 返回其它错误时应答FAIL, 微信稍后重发通知.
+// 报文不是XML时返回400, 签名错误、通知return_code非SUCCESS、解密失败或未设置对应回调时应答FAIL.
+// return_msg为固定文本, 不含错误详情
+type NotifyHandler struct {
+	SecretKey string
+	OnPay     func(notify *PayNotify) error
+	OnRefund  func(notify *RefundNotify) error
+}
+
+func (this *NotifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxNotifyBody))
+	if err != nil {
+		writeNotifyAck(w, http.StatusBadRequest, "FAIL", "Invalid notify body")
+		return
+	}
+	if !bytes.HasPrefix(trimXMLBody(body), []byte("<xml")) {
+		writeNotifyAck(w, http.StatusBadRequest, "FAIL", "Invalid notify body")
+		return
+	}
+	if err = this.dispatch(body); err != nil && !errors.Is(err, ErrStopRetry) {
+		writeNotifyAck(w, http.StatusOK, "FAIL", "Notify not processed")
+		return
+	}
+	writeNotifyAck(w, http.StatusOK, "SUCCESS", "OK")
+}
+
+// dispatch 按通知类型解析并调用对应回调
+func (this *NotifyHandler) dispatch(body []byte) error {
+	notifyType, err := NotifyType(body)
+	if err != nil {
+		return err
+	}
+	if notifyType == NotifyTypeRefund {
+		if this.OnRefund == nil {
+			return fmt.Errorf("Refund notify: %w", ErrNoNotifyCallback)
+		}
+		notify, err := ParseRefundNotify(body, this.SecretKey)
+		if err != nil {
+			return err
+		}
+		return this.OnRefund(notify)
+	}
+	if this.OnPay == nil {
+		return fmt.Errorf("Pay notify: %w", ErrNoNotifyCallback)
+	}
+	notify, err := ParseAndVerifyNotify(body, this.SecretKey)
+	if err != nil {
+		return err
+	}
+	return this.OnPay(notify)
+}
+
+func writeNotifyAck(w http.ResponseWriter, status int, returnCode, returnMsg string) {
+	XML, _ := xml.Marshal(notifyAck{ReturnCode: returnCode, ReturnMsg: returnMsg})
+	w.Header().Set("Content-Type", "application/xml;charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(XML)
+}
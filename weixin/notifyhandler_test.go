@@ -0,0 +1,157 @@
+package weixin
+
+import (
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testPayNotifyXML() string {
+	return signedXML(map[string]string{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"appid":          "wx123",
+		"mch_id":         "10000100",
+		"out_trade_no":   "T1",
+		"transaction_id": "4200000001",
+		"total_fee":      "100",
+	})
+}
+
+// encryptReqInfo 按退款结果通知的方式加密req_info: AES-256-ECB, PKCS#7填充, 密钥为API密钥的md5
+func encryptReqInfo(plain string, secretKey string) string {
+	key := fmt.Sprintf("%x", md5.Sum([]byte(secretKey)))
+	block, _ := aes.NewCipher([]byte(key))
+	size := block.BlockSize()
+	padding := size - len(plain)%size
+	data := []byte(plain + strings.Repeat(string(rune(padding)), padding))
+	for i := 0; i < len(data); i += size {
+		block.Encrypt(data[i:i+size], data[i:i+size])
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func testRefundNotifyXML() string {
+	reqInfo := encryptReqInfo("<root><out_refund_no>R1</out_refund_no><refund_status>SUCCESS</refund_status></root>", testSecretKey)
+	return "<xml><return_code>SUCCESS</return_code><appid>wx123</appid><mch_id>10000100</mch_id><req_info>" + reqInfo + "</req_info></xml>"
+}
+
+func serveNotify(h *NotifyHandler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/notify", strings.NewReader(body)))
+	return w
+}
+
+func ackCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	params, err := XMLToMap(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params["return_code"]
+}
+
+func TestNotifyHandlerAck(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, "SUCCESS"},
+		{ErrStopRetry, "SUCCESS"},
+		{fmt.Errorf("order T1: %w", ErrStopRetry), "SUCCESS"},
+		{errors.New("database unavailable"), "FAIL"},
+	}
+	for _, c := range cases {
+		h := &NotifyHandler{SecretKey: testSecretKey, OnPay: func(notify *PayNotify) error { return c.err }}
+		w := serveNotify(h, testPayNotifyXML())
+		if w.Code != http.StatusOK || ackCode(t, w) != c.want {
+			t.Fatalf("OnPay error %v: status %d ack %s, want %s", c.err, w.Code, w.Body, c.want)
+		}
+	}
+}
+
+func TestNotifyHandlerRejectsNonXML(t *testing.T) {
+	h := &NotifyHandler{SecretKey: testSecretKey, OnPay: func(notify *PayNotify) error { return nil }}
+	if w := serveNotify(h, "GET / HTTP/1.1"); w.Code != http.StatusBadRequest || ackCode(t, w) != "FAIL" {
+		t.Fatalf("status %d ack %s", w.Code, w.Body)
+	}
+}
+
+func TestNotifyHandlerRejectsTamperedSign(t *testing.T) {
+	called := false
+	h := &NotifyHandler{SecretKey: testSecretKey, OnPay: func(notify *PayNotify) error { called = true; return nil }}
+	body := strings.Replace(testPayNotifyXML(), "<total_fee>100</total_fee>", "<total_fee>1</total_fee>", 1)
+	if w := serveNotify(h, body); ackCode(t, w) != "FAIL" || called {
+		t.Fatalf("tampered notify accepted: %s", w.Body)
+	}
+}
+
+func TestNotifyHandlerNilCallback(t *testing.T) {
+	h := &NotifyHandler{SecretKey: testSecretKey}
+	for _, body := range []string{testPayNotifyXML(), testRefundNotifyXML()} {
+		w := serveNotify(h, body)
+		if ackCode(t, w) != "FAIL" {
+			t.Fatalf("ack %s, want FAIL", w.Body)
+		}
+	}
+}
+
+func TestNotifyHandlerDispatchesRefund(t *testing.T) {
+	var got *RefundNotify
+	h := &NotifyHandler{
+		SecretKey: testSecretKey,
+		OnPay:     func(notify *PayNotify) error { t.Fatal("refund notify sent to OnPay"); return nil },
+		OnRefund:  func(notify *RefundNotify) error { got = notify; return nil },
+	}
+	w := serveNotify(h, testRefundNotifyXML())
+	if ackCode(t, w) != "SUCCESS" {
+		t.Fatalf("ack %s", w.Body)
+	}
+	if got == nil || got.Info.OutRefundNo != "R1" || got.Info.RefundStatus != "SUCCESS" {
+		t.Fatalf("refund notify = %+v", got)
+	}
+}
+
+func TestNotifyHandlerRejectsFailedRefundNotify(t *testing.T) {
+	reqInfo := encryptReqInfo("<root><out_refund_no>R1</out_refund_no><refund_status>SUCCESS</refund_status></root>", testSecretKey)
+	// 改写首个分组, 解密结果不再以<开头
+	tampered := []byte(reqInfo)
+	if tampered[2] == 'A' {
+		tampered[2] = 'B'
+	} else {
+		tampered[2] = 'A'
+	}
+	bodies := map[string]string{
+		"return_code FAIL":    "<xml><return_code>FAIL</return_code><return_msg>secret detail</return_msg><appid>wx123</appid><mch_id>10000100</mch_id></xml>",
+		"tampered req_info":   "<xml><return_code>SUCCESS</return_code><appid>wx123</appid><mch_id>10000100</mch_id><req_info>" + string(tampered) + "</req_info></xml>",
+		"req_info not base64": "<xml><return_code>SUCCESS</return_code><appid>wx123</appid><mch_id>10000100</mch_id><req_info>!!</req_info></xml>",
+	}
+	for name, body := range bodies {
+		called := false
+		h := &NotifyHandler{SecretKey: testSecretKey, OnRefund: func(notify *RefundNotify) error { called = true; return nil }}
+		w := serveNotify(h, body)
+		if ackCode(t, w) != "FAIL" || called {
+			t.Fatalf("%s: ack %s, OnRefund called %v", name, w.Body, called)
+		}
+		if strings.Contains(w.Body.String(), "secret detail") || strings.Contains(w.Body.String(), "API secret key") {
+			t.Fatalf("%s: ack leaks error detail: %s", name, w.Body)
+		}
+	}
+}
+
+func TestParseRefundNotifyErrors(t *testing.T) {
+	_, err := ParseRefundNotify([]byte("<xml><return_code>FAIL</return_code><return_msg>error</return_msg></xml>"), testSecretKey)
+	if !errors.Is(err, ErrRefundNotifyFailed) {
+		t.Fatalf("err = %v, want ErrRefundNotifyFailed", err)
+	}
+	body := strings.Replace(testRefundNotifyXML(), "<req_info>", "<req_info>AAAA", 1)
+	if _, err = ParseRefundNotify([]byte(body), testSecretKey); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("err = %v, want ErrDecryptFailed", err)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+	微信查询订单API
+*/
+
+package weixin
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+const (
+	OrderQueryURL string = "https://api.mch.weixin.qq.com/pay/orderquery"
+)
+
+const (
+	TradeStateSuccess    string = "SUCCESS"    // 支付成功
+	TradeStateRefund     string = "REFUND"     // 转入退款
+	TradeStateNotPay     string = "NOTPAY"     // 未支付
+	TradeStateClosed     string = "CLOSED"     // 已关闭
+	TradeStateRevoked    string = "REVOKED"    // 已撤销(付款码支付)
+	TradeStateUserPaying string = "USERPAYING" // 用户支付中(付款码支付)
+	TradeStatePayError   string = "PAYERROR"   // 支付失败
+)
+
+const ErrCodeOrderNotExist string = "ORDERNOTEXIST"
+
+type OrderQueryPayload struct {
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	AppId         string   `json:"appid,omitempty" xml:"appid,omitempty"`                   // R. 应用ID
+	MchId         string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                 // R. 商户号
+	TransactionId string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"` // C. 微信订单号, 与out_trade_no二选一
+	OutTradeNo    string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`     // C. 商户订单号
+	NonceStr      string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`           // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                     // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`           // O. 签名类型,默认MD5
+}
+
+func (this *OrderQueryPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+func (this *OrderQueryPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("transaction_id/out_trade_no", this.TransactionId != "" || this.OutTradeNo != "")
+	v.require("nonce_str", this.NonceStr != "")
+	return v.err()
+}
+
+type OrderQueryResp struct {
+	ReturnCode         string `xml:"return_code"`
+	ReturnMsg          string `xml:"return_msg"`
+	AppId              string `xml:"appid"`
+	MchId              string `xml:"mch_id"`
+	NonceStr           string `xml:"nonce_str"`
+	Sign               string `xml:"sign"`
+	ResultCode         string `xml:"result_code"`
+	ErrCode            string `xml:"err_code"`
+	ErrCodeDes         string `xml:"err_code_des"`
+	DeviceInfo         string `xml:"device_info"`
+	OpenID             string `xml:"openid"`
+	IsSubscribe        string `xml:"is_subscribe"`
+	TradeType          string `xml:"trade_type"`
+	TradeState         string `xml:"trade_state"`
+	BankType           string `xml:"bank_type"`
+	TotalFee           int    `xml:"total_fee"`
+	SettlementTotalFee int    `xml:"settlement_total_fee"`
+	FeeType            string `xml:"fee_type"`
+	CashFee            int    `xml:"cash_fee"`
+	CashFeeType        string `xml:"cash_fee_type"`
+	CouponFee          int    `xml:"coupon_fee"`
+	CouponCount        int    `xml:"coupon_count"`
+	TransactionId      string `xml:"transaction_id"`
+	OutTradeNo         string `xml:"out_trade_no"`
+	Attach             string `xml:"attach"`
+	TimeEnd            string `xml:"time_end"`
+	PromotionDetail    string `xml:"promotion_detail"` // 优惠详情(JSON), 解析结果见Promotions
+	TradeStateDesc     string `xml:"trade_state_desc"`
+
+	Coupons    []Coupon          `xml:"-"` // 代金券使用明细(coupon_*_$n)
+	Promotions []PromotionDetail `xml:"-"`
+}
+
+func (this *OrderQueryResp) IsSuccess() bool {
+	return this.ResultCode == "SUCCESS" && this.ReturnCode == "SUCCESS"
+}
+
+// IsPaid 订单是否已支付(含已转入退款)
+func (this *OrderQueryResp) IsPaid() bool {
+	return this.TradeState == TradeStateSuccess || this.TradeState == TradeStateRefund
+}
+
+// PaidAt 支付完成时间(time_end), 未支付时time_end为空, 返回解析错误
+func (this *OrderQueryResp) PaidAt() (time.Time, error) {
+	return ParseChinaTime(this.TimeEnd)
+}
+
+func OrderQuery(payload *OrderQueryPayload, secretKey string) (response OrderQueryResp, err error) {
+	return OrderQueryWithContext(context.Background(), payload, secretKey)
+}
+
+func OrderQueryWithContext(ctx context.Context, payload *OrderQueryPayload, secretKey string) (response OrderQueryResp, err error) {
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	c := &http.Client{Timeout: DefaultTimeout}
+	body, err1 := doXMLRequest(ctx, c, OrderQueryURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	if response.Coupons, err = parseCoupons(body); err != nil {
+		return
+	}
+	if response.Promotions, err = ParsePromotionDetail(response.PromotionDetail); err != nil {
+		return
+	}
+	return
+}
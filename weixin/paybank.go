@@ -0,0 +1,140 @@
+/*
+	企业付款到银行卡: RSA公钥获取及敏感字段加密
+*/
+
+package weixin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+const (
+	GetPublicKeyURL string = "https://fraud.mch.weixin.qq.com/risk/getpublickey"
+)
+
+type GetPublicKeyPayload struct {
+	XMLName  xml.Name `xml:"xml" json:"-"`
+	MchId    string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`       // R. 商户号
+	NonceStr string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"` // R. 随机字符串
+	Sign     string   `json:"sign,omitempty" xml:"sign,omitempty"`           // R. 签名
+	SignType string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"` // R. 签名类型, 固定MD5
+}
+
+func (this *GetPublicKeyPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+type GetPublicKeyResp struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ResultCode string `xml:"result_code"`
+	ErrCode    string `xml:"err_code"`
+	ErrCodeDes string `xml:"err_code_des"`
+	MchId      string `xml:"mch_id"`
+	PubKey     string `xml:"pub_key"` // PKCS#1格式RSA公钥
+}
+
+func (this *GetPublicKeyResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+// 获取RSA公钥接口的响应不含sign
+func (this *GetPublicKeyResp) unsigned() {}
+
+// 按商户号缓存的RSA公钥, 微信公钥长期不变
+var publicKeyCache = struct {
+	sync.Mutex
+	keys map[string]*rsa.PublicKey
+}{keys: make(map[string]*rsa.PublicKey)}
+
+// ParseWxPublicKey 解析微信返回的PEM格式RSA公钥(PKCS#1或PKIX)
+func ParseWxPublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("Invalid public key: no PEM data")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Invalid public key: not an RSA key")
+	}
+	return rsaPub, nil
+}
+
+// RSAEncryptBankField 使用微信RSA公钥加密收款方银行卡号(enc_bank_no)、姓名(enc_true_name),
+// 填充方式为RSA_PKCS1_OAEP_PADDING(OAEP, SHA-1), 结果Base64编码
+func RSAEncryptBankField(plain string, pub *rsa.PublicKey) (string, error) {
+	cipher, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, []byte(plain), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(cipher), nil
+}
+
+func cachedPublicKey(mchId string) *rsa.PublicKey {
+	publicKeyCache.Lock()
+	defer publicKeyCache.Unlock()
+	return publicKeyCache.keys[mchId]
+}
+
+func GetPublicKey(mchId string, secretKey string, cert string, key string) (*rsa.PublicKey, error) {
+	return GetPublicKeyWithContext(context.Background(), mchId, secretKey, cert, key)
+}
+
+// GetPublicKeyWithContext 获取企业付款到银行卡的RSA公钥, 成功后按商户号缓存
+func GetPublicKeyWithContext(ctx context.Context, mchId string, secretKey string, cert string, key string) (pub *rsa.PublicKey, err error) {
+	if pub = cachedPublicKey(mchId); pub != nil {
+		return
+	}
+	tlsConfig, err := NewTLSConfig(cert, key)
+	if err != nil {
+		return
+	}
+	return GetPublicKeyWithClient(ctx, NewTLSClient(tlsConfig), mchId, secretKey)
+}
+
+// GetPublicKeyWithClient 使用调用方提供的HTTP客户端获取RSA公钥, c的TLS配置须含商户API证书(见NewTLSClient)
+func GetPublicKeyWithClient(ctx context.Context, c *http.Client, mchId string, secretKey string) (pub *rsa.PublicKey, err error) {
+	if pub = cachedPublicKey(mchId); pub != nil {
+		return
+	}
+	if mchId == "" {
+		err = errors.New("Missing required parameters: mch_id")
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	payload := &GetPublicKeyPayload{
+		MchId:    mchId,
+		NonceStr: NonceStr(),
+		SignType: SignTypeMD5,
+	}
+	response := GetPublicKeyResp{}
+	if _, err = doXMLRequest(ctx, c, GetPublicKeyURL, payload, secretKey, &response); err != nil {
+		return
+	}
+	if pub, err = ParseWxPublicKey([]byte(response.PubKey)); err != nil {
+		return
+	}
+	publicKeyCache.Lock()
+	publicKeyCache.keys[mchId] = pub
+	publicKeyCache.Unlock()
+	return
+}
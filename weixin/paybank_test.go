@@ -0,0 +1,60 @@
+package weixin
+
+import (
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+)
+
+// testdata/wxpay_rsa_public.pem为PKCS#1格式(BEGIN RSA PUBLIC KEY), 与risk/getpublickey返回的pub_key格式相同
+func TestRSAEncryptBankField(t *testing.T) {
+	pubPEM, err := ioutil.ReadFile("testdata/wxpay_rsa_public.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ParseWxPublicKey(pubPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPEM, err := ioutil.ReadFile("testdata/wxpay_rsa_private.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(privPEM)
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Equal(pub) {
+		t.Fatal("parsed public key does not match the fixture key pair")
+	}
+
+	encrypted, err := RSAEncryptBankField("6225760000000000", pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cipherText) != pub.Size() {
+		t.Fatalf("ciphertext length = %d, want key size %d", len(cipherText), pub.Size())
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), nil, priv, cipherText, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "6225760000000000" {
+		t.Fatalf("plain = %q", plain)
+	}
+}
+
+func TestParseWxPublicKeyRejectsInvalidPEM(t *testing.T) {
+	if _, err := ParseWxPublicKey([]byte("not a key")); err == nil {
+		t.Fatal("expected error for non-PEM data")
+	}
+}
@@ -0,0 +1,44 @@
+/*
+	连通性及商户配置检查
+*/
+
+package weixin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrConnectivity = errors.New("WeChat pay gateway unreachable")
+	ErrAuth         = errors.New("WeChat pay rejected merchant credentials")
+)
+
+// Ping 查询一个不存在的订单, 返回ORDERNOTEXIST即视为网关可达且appid/mch_id/密钥有效.
+// 网络或报文错误返回包装ErrConnectivity的错误, 鉴权或配置错误返回包装ErrAuth的错误
+func Ping(ctx context.Context, appId, mchId, secretKey string) error {
+	if err := CheckSecretKey(secretKey); err != nil {
+		return err
+	}
+	payload := &OrderQueryPayload{
+		AppId:      appId,
+		MchId:      mchId,
+		OutTradeNo: "PING" + NonceStr()[:28],
+		NonceStr:   NonceStr(),
+	}
+	if err := payload.PreSignCheck(); err != nil {
+		return err
+	}
+	response, err := OrderQueryWithContext(ctx, payload, secretKey)
+	if err == nil || response.ErrCode == ErrCodeOrderNotExist {
+		return nil
+	}
+	if response.ReturnCode == "" {
+		return fmt.Errorf("%w: %v", ErrConnectivity, err)
+	}
+	if response.ReturnCode != "SUCCESS" {
+		return fmt.Errorf("%w: %s", ErrAuth, response.ReturnMsg)
+	}
+	return fmt.Errorf("%w: %s %s", ErrAuth, response.ErrCode, response.ErrCodeDes)
+}
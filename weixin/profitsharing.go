@@ -0,0 +1,381 @@
+/*
+	微信分账API
+*/
+
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ProfitSharingAddReceiverURL    string = "https://api.mch.weixin.qq.com/pay/profitsharingaddreceiver"
+	ProfitSharingRemoveReceiverURL string = "https://api.mch.weixin.qq.com/pay/profitsharingremovereceiver"
+	ProfitSharingFinishURL         string = "https://api.mch.weixin.qq.com/secapi/pay/profitsharingfinish"
+	ProfitSharingAmountQueryURL    string = "https://api.mch.weixin.qq.com/pay/profitsharingorderamountquery"
+	ProfitSharingReturnURL         string = "https://api.mch.weixin.qq.com/secapi/pay/profitsharingreturn"
+)
+
+const (
+	ReturnResultProcessing string = "PROCESSING" // 处理中
+	ReturnResultSuccess    string = "SUCCESS"    // 已成功
+	ReturnResultFailed     string = "FAILED"     // 已失败
+)
+
+const (
+	ReceiverTypeMerchant          string = "MERCHANT_ID"         // 商户号
+	ReceiverTypePersonalOpenID    string = "PERSONAL_OPENID"     // 个人openid(由父商户appid转换得到)
+	ReceiverTypePersonalSubOpenID string = "PERSONAL_SUB_OPENID" // 个人sub_openid(由子商户appid转换得到)
+)
+
+type ProfitSharingReceiver struct {
+	Type           string `json:"type"`                      // R. 分账接收方类型
+	Account        string `json:"account"`                   // R. 分账接收方账号
+	Name           string `json:"name,omitempty"`            // C. 商户全称(MERCHANT_ID必传)或个人真实姓名(个人类型选传, 传则校验)
+	RelationType   string `json:"relation_type,omitempty"`   // R. 与分账方的关系类型(添加时必传)
+	CustomRelation string `json:"custom_relation,omitempty"` // C. 自定义的分账关系(relation_type为CUSTOM时必传)
+}
+
+type ProfitSharingReceiverPayload struct {
+	XMLName  xml.Name `xml:"xml" json:"-"`
+	MchId    string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`       // R. 商户号
+	AppId    string   `json:"appid,omitempty" xml:"appid,omitempty"`         // R. 应用ID
+	NonceStr string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"` // R. 随机字符串
+	Sign     string   `json:"sign,omitempty" xml:"sign,omitempty"`           // R. 签名
+	SignType string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"` // R. 签名类型, 仅支持HMAC-SHA256
+	Receiver string   `json:"receiver,omitempty" xml:"receiver,omitempty"`   // R. 分账接收方(JSON)
+}
+
+func (this *ProfitSharingReceiverPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+type ProfitSharingReceiverResp struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ResultCode string `xml:"result_code"`
+	ErrCode    string `xml:"err_code"`
+	ErrCodeDes string `xml:"err_code_des"`
+	MchId      string `xml:"mch_id"`
+	AppId      string `xml:"appid"`
+	NonceStr   string `xml:"nonce_str"`
+	Sign       string `xml:"sign"`
+	Receiver   string `xml:"receiver"` // 分账接收方(JSON), 解析结果见ReceiverInfo
+
+	ReceiverInfo ProfitSharingReceiver `xml:"-"`
+}
+
+func (this *ProfitSharingReceiverResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func AddProfitShareReceiver(appId, mchId string, receiver ProfitSharingReceiver, secretKey string) (ProfitSharingReceiverResp, error) {
+	return AddProfitShareReceiverWithContext(context.Background(), appId, mchId, receiver, secretKey)
+}
+
+// AddProfitShareReceiverWithContext 添加分账接收方, 无需证书
+func AddProfitShareReceiverWithContext(ctx context.Context, appId, mchId string, receiver ProfitSharingReceiver, secretKey string) (response ProfitSharingReceiverResp, err error) {
+	v := &ValidationError{}
+	v.require("relation_type", receiver.RelationType != "")
+	v.require("name", receiver.Type != ReceiverTypeMerchant || receiver.Name != "")
+	if err = v.err(); err != nil {
+		return
+	}
+	return profitSharingReceiver(ctx, ProfitSharingAddReceiverURL, appId, mchId, receiver, secretKey)
+}
+
+func RemoveProfitShareReceiver(appId, mchId string, receiver ProfitSharingReceiver, secretKey string) (ProfitSharingReceiverResp, error) {
+	return RemoveProfitShareReceiverWithContext(context.Background(), appId, mchId, receiver, secretKey)
+}
+
+// RemoveProfitShareReceiverWithContext 删除分账接收方, 仅需type及account, 无需证书
+func RemoveProfitShareReceiverWithContext(ctx context.Context, appId, mchId string, receiver ProfitSharingReceiver, secretKey string) (ProfitSharingReceiverResp, error) {
+	return profitSharingReceiver(ctx, ProfitSharingRemoveReceiverURL, appId, mchId, receiver, secretKey)
+}
+
+func profitSharingReceiver(ctx context.Context, url, appId, mchId string, receiver ProfitSharingReceiver, secretKey string) (response ProfitSharingReceiverResp, err error) {
+	v := &ValidationError{}
+	v.require("appid", appId != "")
+	v.require("mch_id", mchId != "")
+	v.require("type", receiver.Type != "")
+	v.require("account", receiver.Account != "")
+	if err = v.err(); err != nil {
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	receiverJSON, err := json.Marshal(receiver)
+	if err != nil {
+		return
+	}
+	payload := &ProfitSharingReceiverPayload{
+		MchId:    mchId,
+		AppId:    appId,
+		NonceStr: NonceStr(),
+		SignType: resolveSignType("", url),
+		Receiver: string(receiverJSON),
+	}
+	c := &http.Client{Timeout: DefaultTimeout}
+	_, err1 := doXMLRequest(ctx, c, url, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	if response.Receiver != "" {
+		err = json.Unmarshal([]byte(response.Receiver), &response.ReceiverInfo)
+	}
+	return
+}
+
+type ProfitSharingFinishPayload struct {
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	MchId         string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                 // R. 商户号
+	AppId         string   `json:"appid,omitempty" xml:"appid,omitempty"`                   // R. 应用ID
+	NonceStr      string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`           // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                     // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`           // R. 签名类型, 仅支持HMAC-SHA256
+	TransactionId string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"` // R. 微信订单号
+	OutOrderNo    string   `json:"out_order_no,omitempty" xml:"out_order_no,omitempty"`     // R. 商户分账单号
+	Description   string   `json:"description,omitempty" xml:"description,omitempty"`       // R. 分账完结描述
+}
+
+func (this *ProfitSharingFinishPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+type ProfitSharingFinishResp struct {
+	ReturnCode    string `xml:"return_code"`
+	ReturnMsg     string `xml:"return_msg"`
+	ResultCode    string `xml:"result_code"`
+	ErrCode       string `xml:"err_code"`
+	ErrCodeDes    string `xml:"err_code_des"`
+	MchId         string `xml:"mch_id"`
+	AppId         string `xml:"appid"`
+	NonceStr      string `xml:"nonce_str"`
+	Sign          string `xml:"sign"`
+	TransactionId string `xml:"transaction_id"`
+	OutOrderNo    string `xml:"out_order_no"`
+	OrderId       string `xml:"order_id"` // 微信分账单号
+	Result        string `xml:"result"`   // 分账单状态
+}
+
+func (this *ProfitSharingFinishResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func ProfitSharingFinish(transactionId, outOrderNo, description, appId, mchId, secretKey string, cert tls.Certificate) (ProfitSharingFinishResp, error) {
+	return ProfitSharingFinishWithContext(context.Background(), transactionId, outOrderNo, description, appId, mchId, secretKey, cert)
+}
+
+// ProfitSharingFinishWithContext 完结分账, 解冻订单剩余待分账资金给商户, 需要证书
+func ProfitSharingFinishWithContext(ctx context.Context, transactionId, outOrderNo, description, appId, mchId, secretKey string, cert tls.Certificate) (response ProfitSharingFinishResp, err error) {
+	if err = requireCert(cert); err != nil {
+		return
+	}
+	return ProfitSharingFinishWithClient(ctx, newCertClient(cert), transactionId, outOrderNo, description, appId, mchId, secretKey)
+}
+
+// ProfitSharingFinishWithClient 使用调用方提供的HTTP客户端完结分账, c的TLS配置须含商户API证书(见NewTLSClient)
+func ProfitSharingFinishWithClient(ctx context.Context, c *http.Client, transactionId, outOrderNo, description, appId, mchId, secretKey string) (response ProfitSharingFinishResp, err error) {
+	v := &ValidationError{}
+	v.require("appid", appId != "")
+	v.require("mch_id", mchId != "")
+	v.require("transaction_id", transactionId != "")
+	v.require("out_order_no", outOrderNo != "")
+	v.require("description", description != "")
+	if err = v.err(); err != nil {
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	payload := &ProfitSharingFinishPayload{
+		MchId:         mchId,
+		AppId:         appId,
+		NonceStr:      NonceStr(),
+		SignType:      resolveSignType("", ProfitSharingFinishURL),
+		TransactionId: transactionId,
+		OutOrderNo:    outOrderNo,
+		Description:   description,
+	}
+	_, err1 := doXMLRequest(ctx, c, ProfitSharingFinishURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+	}
+	return
+}
+
+type ProfitSharingAmountQueryPayload struct {
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	MchId         string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                 // R. 商户号
+	TransactionId string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"` // R. 微信订单号
+	NonceStr      string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`           // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                     // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`           // R. 签名类型, 仅支持HMAC-SHA256
+}
+
+func (this *ProfitSharingAmountQueryPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+type ProfitSharingAmountQueryResp struct {
+	ReturnCode    string `xml:"return_code"`
+	ReturnMsg     string `xml:"return_msg"`
+	ResultCode    string `xml:"result_code"`
+	ErrCode       string `xml:"err_code"`
+	ErrCodeDes    string `xml:"err_code_des"`
+	MchId         string `xml:"mch_id"`
+	TransactionId string `xml:"transaction_id"`
+	UnsplitAmount int    `xml:"unsplit_amount"` // 订单剩余待分金额(分)
+	NonceStr      string `xml:"nonce_str"`
+	Sign          string `xml:"sign"`
+}
+
+func (this *ProfitSharingAmountQueryResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func ProfitSharingOrderAmountQuery(transactionId, mchId, secretKey string) (int, error) {
+	return ProfitSharingOrderAmountQueryWithContext(context.Background(), transactionId, mchId, secretKey)
+}
+
+// ProfitSharingOrderAmountQueryWithContext 查询订单剩余待分金额(分), 无需证书
+func ProfitSharingOrderAmountQueryWithContext(ctx context.Context, transactionId, mchId, secretKey string) (unsplitAmount int, err error) {
+	return ProfitSharingOrderAmountQueryWithClient(ctx, &http.Client{Timeout: DefaultTimeout}, transactionId, mchId, secretKey)
+}
+
+// ProfitSharingOrderAmountQueryWithClient 使用调用方提供的HTTP客户端查询订单剩余待分金额(分),
+// 响应缺少unsplit_amount或其不是非负整数时返回错误
+func ProfitSharingOrderAmountQueryWithClient(ctx context.Context, c *http.Client, transactionId, mchId, secretKey string) (unsplitAmount int, err error) {
+	v := &ValidationError{}
+	v.require("mch_id", mchId != "")
+	v.require("transaction_id", transactionId != "")
+	if err = v.err(); err != nil {
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	payload := &ProfitSharingAmountQueryPayload{
+		MchId:         mchId,
+		TransactionId: transactionId,
+		NonceStr:      NonceStr(),
+		SignType:      resolveSignType("", ProfitSharingAmountQueryURL),
+	}
+	response := ProfitSharingAmountQueryResp{}
+	body, err := doXMLRequest(ctx, c, ProfitSharingAmountQueryURL, payload, secretKey, &response)
+	if err != nil {
+		return
+	}
+	params, err := XMLToMap(body)
+	if err != nil {
+		return
+	}
+	amount, err1 := strconv.Atoi(params["unsplit_amount"])
+	if err1 != nil || amount < 0 {
+		err = errors.New("Invalid unsplit_amount in response: " + strconv.Quote(params["unsplit_amount"]))
+		return
+	}
+	unsplitAmount = amount
+	return
+}
+
+type ProfitSharingReturnPayload struct {
+	XMLName           xml.Name `xml:"xml" json:"-"`
+	MchId             string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                           // R. 商户号
+	AppId             string   `json:"appid,omitempty" xml:"appid,omitempty"`                             // R. 应用ID
+	NonceStr          string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`                     // R. 随机字符串
+	Sign              string   `json:"sign,omitempty" xml:"sign,omitempty"`                               // R. 签名
+	SignType          string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`                     // R. 签名类型, 仅支持HMAC-SHA256
+	OrderId           string   `json:"order_id,omitempty" xml:"order_id,omitempty"`                       // C. 微信分账单号, 与out_order_no二选一
+	OutOrderNo        string   `json:"out_order_no,omitempty" xml:"out_order_no,omitempty"`               // C. 商户分账单号
+	OutReturnNo       string   `json:"out_return_no,omitempty" xml:"out_return_no,omitempty"`             // R. 商户回退单号
+	ReturnAccountType string   `json:"return_account_type,omitempty" xml:"return_account_type,omitempty"` // R. 回退方类型, 仅支持MERCHANT_ID
+	ReturnAccount     string   `json:"return_account,omitempty" xml:"return_account,omitempty"`           // R. 回退方商户号
+	ReturnAmount      int      `json:"return_amount,omitempty" xml:"return_amount,omitempty"`             // R. 回退金额(分)
+	Description       string   `json:"description,omitempty" xml:"description,omitempty"`                 // R. 回退描述
+}
+
+func (this *ProfitSharingReturnPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+func (this *ProfitSharingReturnPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("mch_id", this.MchId != "")
+	v.require("appid", this.AppId != "")
+	v.require("nonce_str", this.NonceStr != "")
+	v.require("order_id/out_order_no", this.OrderId != "" || this.OutOrderNo != "")
+	v.require("out_return_no", this.OutReturnNo != "")
+	v.require("return_account_type", this.ReturnAccountType != "")
+	v.check("return_account_type", this.ReturnAccountType == "" || this.ReturnAccountType == ReceiverTypeMerchant)
+	v.require("return_account", this.ReturnAccount != "")
+	v.require("return_amount", this.ReturnAmount != 0)
+	v.check("return_amount", this.ReturnAmount >= 0)
+	v.require("description", this.Description != "")
+	return v.err()
+}
+
+type ProfitSharingReturnResp struct {
+	ReturnCode        string `xml:"return_code"`
+	ReturnMsg         string `xml:"return_msg"`
+	ResultCode        string `xml:"result_code"`
+	ErrCode           string `xml:"err_code"`
+	ErrCodeDes        string `xml:"err_code_des"`
+	MchId             string `xml:"mch_id"`
+	AppId             string `xml:"appid"`
+	NonceStr          string `xml:"nonce_str"`
+	Sign              string `xml:"sign"`
+	OrderId           string `xml:"order_id"`
+	OutOrderNo        string `xml:"out_order_no"`
+	OutReturnNo       string `xml:"out_return_no"`
+	ReturnNo          string `xml:"return_no"` // 微信回退单号
+	ReturnAccountType string `xml:"return_account_type"`
+	ReturnAccount     string `xml:"return_account"`
+	ReturnAmount      int    `xml:"return_amount"`
+	Description       string `xml:"description"`
+	Result            string `xml:"result"`      // 回退结果: PROCESSING/SUCCESS/FAILED
+	FailReason        string `xml:"fail_reason"` // 失败原因
+	FinishTime        string `xml:"finish_time"`
+}
+
+func (this *ProfitSharingReturnResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func ProfitSharingReturn(payload *ProfitSharingReturnPayload, secretKey string, cert tls.Certificate) (ProfitSharingReturnResp, error) {
+	return ProfitSharingReturnWithContext(context.Background(), payload, secretKey, cert)
+}
+
+// ProfitSharingReturnWithContext 分账回退, 从分账接收方回退资金至分账方, 需要证书.
+// 返回的result为PROCESSING时需稍后查询回退结果
+func ProfitSharingReturnWithContext(ctx context.Context, payload *ProfitSharingReturnPayload, secretKey string, cert tls.Certificate) (response ProfitSharingReturnResp, err error) {
+	if err = requireCert(cert); err != nil {
+		return
+	}
+	return ProfitSharingReturnWithClient(ctx, newCertClient(cert), payload, secretKey)
+}
+
+// ProfitSharingReturnWithClient 使用调用方提供的HTTP客户端分账回退, c的TLS配置须含商户API证书(见NewTLSClient)
+func ProfitSharingReturnWithClient(ctx context.Context, c *http.Client, payload *ProfitSharingReturnPayload, secretKey string) (response ProfitSharingReturnResp, err error) {
+	payload.SignType = resolveSignType(payload.SignType, ProfitSharingReturnURL)
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	_, err1 := doXMLRequest(ctx, c, ProfitSharingReturnURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+	}
+	return
+}
@@ -0,0 +1,77 @@
+package weixin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProfitSharingFinishSignsHMACSHA256(t *testing.T) {
+	VerifyResponses = true
+	defer func() { VerifyResponses = false }()
+	response := map[string]interface{}{"return_code": "SUCCESS", "result_code": "SUCCESS", "order_id": "3008450740201411110007820472"}
+	responseXML := "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>" +
+		"<order_id>3008450740201411110007820472</order_id><sign>" +
+		SignWithType(response, testSecretKey, SignTypeHMACSHA256) + "</sign></xml>"
+	var bodies []string
+	server := newXMLServer(t, http.StatusOK, responseXML, nil)
+	c := redirectClient(server)
+	c.Transport = recordTransport{c.Transport, &bodies}
+	resp, err := ProfitSharingFinishWithClient(context.Background(), c, "4208450740201411110007820472", "P20150806125346", "分账已完成", "wx123", "10000100", testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OrderId != "3008450740201411110007820472" {
+		t.Fatalf("order_id = %q", resp.OrderId)
+	}
+	if !strings.Contains(bodies[0], "<sign_type>HMAC-SHA256</sign_type>") {
+		t.Fatalf("request lacks sign_type: %s", bodies[0])
+	}
+	sent, err := XMLToMap([]byte(bodies[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SignWithType(map[string]interface{}{
+		"mch_id": "10000100", "appid": "wx123", "nonce_str": sent["nonce_str"], "sign_type": SignTypeHMACSHA256,
+		"transaction_id": "4208450740201411110007820472", "out_order_no": "P20150806125346", "description": "分账已完成",
+	}, testSecretKey, SignTypeHMACSHA256)
+	if sent["sign"] != want {
+		t.Fatalf("sign = %s, want %s", sent["sign"], want)
+	}
+}
+
+func TestProfitSharingOrderAmountQuery(t *testing.T) {
+	const prefix = "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><mch_id>10000100</mch_id>" +
+		"<transaction_id>4208450740201411110007820472</transaction_id>"
+	cases := []struct {
+		name    string
+		amount  string
+		want    int
+		wantErr bool
+	}{
+		{"amount", "<unsplit_amount>1000</unsplit_amount>", 1000, false},
+		{"zero", "<unsplit_amount>0</unsplit_amount>", 0, false},
+		{"missing", "", 0, true},
+		{"empty", "<unsplit_amount></unsplit_amount>", 0, true},
+		{"non-numeric", "<unsplit_amount>10.5</unsplit_amount>", 0, true},
+		{"negative", "<unsplit_amount>-1</unsplit_amount>", 0, true},
+	}
+	for _, c := range cases {
+		var requests []map[string]string
+		server := newXMLServer(t, http.StatusOK, prefix+c.amount+"</xml>", &requests)
+		amount, err := ProfitSharingOrderAmountQueryWithClient(context.Background(), redirectClient(server), "4208450740201411110007820472", "10000100", testSecretKey)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: amount = %d, want error", c.name, amount)
+			}
+			continue
+		}
+		if err != nil || amount != c.want {
+			t.Errorf("%s: amount = %d, err = %v, want %d", c.name, amount, err, c.want)
+		}
+		if requests[0]["sign_type"] != SignTypeHMACSHA256 {
+			t.Errorf("%s: sign_type = %q", c.name, requests[0]["sign_type"])
+		}
+	}
+}
@@ -0,0 +1,45 @@
+/*
+	优惠详情(promotion_detail)
+*/
+
+package weixin
+
+import (
+	"encoding/json"
+)
+
+type PromotionDetail struct {
+	PromotionID        string           `json:"promotion_id"`        // 券或立减优惠ID
+	Name               string           `json:"name"`                // 优惠名称
+	Scope              string           `json:"scope"`               // 优惠范围: GLOBAL全场/SINGLE单品
+	Type               string           `json:"type"`                // 优惠类型: COUPON代金券/DISCOUNT优惠券
+	Amount             int              `json:"amount"`              // 优惠券面额(分)
+	ActivityID         string           `json:"activity_id"`         // 活动ID
+	WxpayContribute    int              `json:"wxpay_contribute"`    // 微信出资(分)
+	MerchantContribute int              `json:"merchant_contribute"` // 商户出资(分)
+	OtherContribute    int              `json:"other_contribute"`    // 其他出资方出资(分)
+	GoodsDetail        []PromotionGoods `json:"goods_detail"`        // 单品优惠明细
+}
+
+type PromotionGoods struct {
+	GoodsID        string `json:"goods_id"`        // 商品编码
+	GoodsRemark    string `json:"goods_remark"`    // 商品备注
+	Quantity       int    `json:"quantity"`        // 商品数量
+	Price          int    `json:"price"`           // 商品单价(分)
+	DiscountAmount int    `json:"discount_amount"` // 商品优惠金额(分)
+}
+
+// ParsePromotionDetail 解析响应中JSON字符串格式的promotion_detail
+func ParsePromotionDetail(promotionDetail string) (promotions []PromotionDetail, err error) {
+	if promotionDetail == "" {
+		return
+	}
+	wrapper := struct {
+		PromotionDetail []PromotionDetail `json:"promotion_detail"`
+	}{}
+	if err = json.Unmarshal([]byte(promotionDetail), &wrapper); err != nil {
+		return
+	}
+	promotions = wrapper.PromotionDetail
+	return
+}
@@ -0,0 +1,57 @@
+package weixin
+
+import (
+	"strings"
+	"testing"
+)
+
+// promotionDetailJSON 一笔订单同时使用全场代金券及两件商品的单品优惠
+const promotionDetailJSON = `{"promotion_detail":[` +
+	`{"promotion_id":"109519","name":"单品惠-6","scope":"SINGLE","type":"DISCOUNT","amount":5,"activity_id":"931386",` +
+	`"wxpay_contribute":0,"merchant_contribute":5,"other_contribute":0,"goods_detail":[` +
+	`{"goods_id":"a_goods1","goods_remark":"商品备注","quantity":7,"price":1,"discount_amount":4},` +
+	`{"goods_id":"a_goods2","goods_remark":"商品备注","quantity":1,"price":2,"discount_amount":1}]},` +
+	`{"promotion_id":"109520","name":"全场代金券","scope":"GLOBAL","type":"COUPON","amount":3,"activity_id":"931387",` +
+	`"wxpay_contribute":1,"merchant_contribute":2,"other_contribute":0}]}`
+
+func TestParsePromotionDetailMultiple(t *testing.T) {
+	promotions, err := ParsePromotionDetail(promotionDetailJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promotions) != 2 {
+		t.Fatalf("promotions = %+v, want 2", promotions)
+	}
+	single, global := promotions[0], promotions[1]
+	if single.PromotionID != "109519" || single.Scope != "SINGLE" || single.Type != "DISCOUNT" || single.Amount != 5 || single.MerchantContribute != 5 {
+		t.Fatalf("single promotion = %+v", single)
+	}
+	if len(single.GoodsDetail) != 2 || single.GoodsDetail[0] != (PromotionGoods{GoodsID: "a_goods1", GoodsRemark: "商品备注", Quantity: 7, Price: 1, DiscountAmount: 4}) ||
+		single.GoodsDetail[1].GoodsID != "a_goods2" || single.GoodsDetail[1].DiscountAmount != 1 {
+		t.Fatalf("goods_detail = %+v", single.GoodsDetail)
+	}
+	if global.PromotionID != "109520" || global.Scope != "GLOBAL" || global.Type != "COUPON" || global.Amount != 3 ||
+		global.WxpayContribute != 1 || global.MerchantContribute != 2 || len(global.GoodsDetail) != 0 {
+		t.Fatalf("global promotion = %+v", global)
+	}
+}
+
+func TestParseNotifyPromotionDetail(t *testing.T) {
+	body := strings.Replace(samplePayNotifyXML(), "</xml>", "<promotion_detail><![CDATA["+promotionDetailJSON+"]]></promotion_detail></xml>", 1)
+	notify, err := ParseNotify([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notify.Promotions) != 2 || notify.Promotions[0].GoodsDetail[1].GoodsID != "a_goods2" || notify.Promotions[1].Name != "全场代金券" {
+		t.Fatalf("promotions = %+v", notify.Promotions)
+	}
+}
+
+func TestParsePromotionDetailInvalid(t *testing.T) {
+	if promotions, err := ParsePromotionDetail(""); err != nil || promotions != nil {
+		t.Fatalf("empty promotion_detail: %+v, %v", promotions, err)
+	}
+	if _, err := ParsePromotionDetail("{"); err == nil {
+		t.Fatal("expected error for malformed promotion_detail")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+	通用v2接口调用
+*/
+
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// DoRaw 签名params并以<xml>报文POST至url, 返回原始响应报文, 用于调用尚未封装的v2接口.
+// 不做参数检查及响应验签, 签名类型取params中的sign_type, 未指定时取DefaultSignTypeFor(url),
+// 默认签名类型非MD5时写入请求的sign_type;
+// 需要证书的接口传入cert, 否则传nil. 仅供熟悉接口文档的调用方使用
+func DoRaw(ctx context.Context, url string, params map[string]interface{}, secretKey string, cert *tls.Certificate) (body []byte, err error) {
+	c := &http.Client{Timeout: DefaultTimeout}
+	if cert != nil {
+		if err = requireCert(*cert); err != nil {
+			return
+		}
+		c = newCertClient(*cert)
+	}
+	return DoRawWithClient(ctx, c, url, params, secretKey)
+}
+
+// DoRawWithClient 同DoRaw, 使用调用方提供的HTTP客户端, 需要证书的接口由c的TLS配置提供商户API证书
+func DoRawWithClient(ctx context.Context, c *http.Client, url string, params map[string]interface{}, secretKey string) (body []byte, err error) {
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	pm := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		if k != "sign" {
+			pm[k] = v
+		}
+	}
+	signType := ""
+	if v, ok := pm["sign_type"]; ok {
+		signType = fmt.Sprint(v)
+	}
+	signType = resolveSignType(signType, url)
+	if signType != SignTypeMD5 {
+		pm["sign_type"] = signType
+	}
+	pm["sign"] = SignWithType(pm, secretKey, signType)
+	keys := make([]string, 0, len(pm))
+	for k := range pm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	raw := struct {
+		XMLName xml.Name     `xml:"xml"`
+		Params  []extraParam `xml:",any"`
+	}{}
+	for _, k := range keys {
+		raw.Params = append(raw.Params, extraParam{XMLName: xml.Name{Local: k}, Value: fmt.Sprint(pm[k])})
+	}
+	XML, err1 := xml.Marshal(raw)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	body, _, err = postXML(ctx, c, url, XML)
+	return
+}
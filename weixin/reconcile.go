@@ -0,0 +1,66 @@
+/*
+	对账: 比对本地订单与微信订单查询结果
+*/
+
+package weixin
+
+import (
+	"strconv"
+)
+
+type LocalOrder struct {
+	OutTradeNo string // 商户订单号
+	TotalFee   int    // 订单金额(分)
+	Paid       bool   // 本地是否已支付
+}
+
+type Discrepancy struct {
+	OutTradeNo string // 商户订单号
+	Field      string // 不一致字段: total_fee/trade_state
+	Local      string // 本地值
+	Remote     string // 微信值
+}
+
+// Reconcile 逐笔查询本地订单, 返回金额或支付状态不一致的记录.
+// 微信返回ORDERNOTEXIST的订单记为trade_state不一致, 其它查询错误中止对账.
+func Reconcile(localOrders []LocalOrder, query func(outTradeNo string) (OrderQueryResp, error)) (discrepancies []Discrepancy, err error) {
+	for _, order := range localOrders {
+		remote, queryErr := query(order.OutTradeNo)
+		if queryErr != nil {
+			if remote.ErrCode != ErrCodeOrderNotExist {
+				err = queryErr
+				return
+			}
+			if order.Paid {
+				discrepancies = append(discrepancies, Discrepancy{
+					OutTradeNo: order.OutTradeNo,
+					Field:      "trade_state",
+					Local:      TradeStateSuccess,
+					Remote:     ErrCodeOrderNotExist,
+				})
+			}
+			continue
+		}
+		if order.TotalFee != remote.TotalFee {
+			discrepancies = append(discrepancies, Discrepancy{
+				OutTradeNo: order.OutTradeNo,
+				Field:      "total_fee",
+				Local:      strconv.Itoa(order.TotalFee),
+				Remote:     strconv.Itoa(remote.TotalFee),
+			})
+		}
+		if order.Paid != remote.IsPaid() {
+			local := TradeStateNotPay
+			if order.Paid {
+				local = TradeStateSuccess
+			}
+			discrepancies = append(discrepancies, Discrepancy{
+				OutTradeNo: order.OutTradeNo,
+				Field:      "trade_state",
+				Local:      local,
+				Remote:     remote.TradeState,
+			})
+		}
+	}
+	return
+}
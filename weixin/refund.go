@@ -1,34 +1,46 @@
 package weixin
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"encoding/xml"
-	"errors"
-	"fmt"
-	"io/ioutil"
 	"net/http"
+	"sync"
 )
 
 const (
 	RefundURL = "https://api.mch.weixin.qq.com/secapi/pay/refund"
 )
 
+const (
+	RefundSourceUnsettledFunds string = "REFUND_SOURCE_UNSETTLED_FUNDS" // 未结算资金退款(默认)
+	RefundSourceRechargeFunds  string = "REFUND_SOURCE_RECHARGE_FUNDS"  // 可用余额退款
+)
+
 type RefundPayload struct {
-	AppID         string `json:"appid" xml:"appid"`                                         // R. APPID
-	MchID         string `json:"mch_id" xml:"mch_id"`                                       // R. 商户号
-	DeviceInfo    string `json:"device_info,omitempty "xml:"device_info,omitempty"`         // O. 设备号
-	NonceStr      string `json:"nonce_str" xml:"nonce_str"`                                 // R. 随机字符串
-	Sign          string `json:"sign,omitempty" xml:"sign,omitempty"`                       // R. 签名
-	SignType      string `json:"sign_type,omitempty" xml:"sign_type,omitempty"`             // O. 签名类型
-	OutTradeNo    string `json:"out_trade_no,omitempty "xml:"out_trade_no,omitempty"`       // R. 商户订单号
-	TransactionID string `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"`   // C. 微信订单号
-	OutRefundNo   string `json:"out_refund_no" xml:"out_refund_no"`                         // C. 商户退款号
-	TotalFee      int    `json:"total_fee" xml:"total_fee"`                                 // R. 订单金额
-	RefundFee     int    `json:"refund_fee" xml:"refund_fee"`                               // R. 退款金额
-	OpUserID      string `json:"op_user_id" xml:"op_user_id"`                               // R. 操作员账号
-	RefundAccount string `json:"refund_account,omitempty" xml:"refund_account,omitempty"`   // O. 退款资金来源
-	RfundFeeType  string `json:"refund_fee_type,omitempty" xml:"refund_fee_type,omitempty"` // O. 货币类型
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	AppID         string   `json:"appid" xml:"appid"`                                         // R. APPID
+	MchID         string   `json:"mch_id" xml:"mch_id"`                                       // R. 商户号
+	DeviceInfo    string   `json:"device_info,omitempty" xml:"device_info,omitempty"`         // O. 设备号
+	NonceStr      string   `json:"nonce_str" xml:"nonce_str"`                                 // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                       // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`             // O. 签名类型
+	OutTradeNo    string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`       // R. 商户订单号
+	TransactionID string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"`   // C. 微信订单号
+	OutRefundNo   string   `json:"out_refund_no" xml:"out_refund_no"`                         // C. 商户退款号
+	TotalFee      int      `json:"total_fee" xml:"total_fee"`                                 // R. 订单金额(分)
+	RefundFee     int      `json:"refund_fee" xml:"refund_fee"`                               // R. 退款金额(分)
+	OpUserID      string   `json:"op_user_id" xml:"op_user_id"`                               // R. 操作员账号
+	RefundAccount string   `json:"refund_account,omitempty" xml:"refund_account,omitempty"`   // O. 退款资金来源(RefundSource*), 默认未结算资金
+	RfundFeeType  string   `json:"refund_fee_type,omitempty" xml:"refund_fee_type,omitempty"` // O. 货币类型
+
+	// SettlementTotalFee 应结订单金额(分), 订单使用了非充值代金券时为total_fee减去代金券金额.
+	// 仅用于本地校验refund_fee不超过可退金额, 不发送给微信
+	SettlementTotalFee int `json:"-" xml:"-"`
+}
+
+func (this *RefundPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
 }
 
 type RefundResponse struct {
@@ -52,6 +64,10 @@ type RefundResponse struct {
 	SettlementRefundFee int    `xml:"settlement_refund_fee"`
 	FeeType             string `xml:"fee_type"`
 	CashFee             int    `xml:"cash_fee"`
+	CouponRefundFee     int    `xml:"coupon_refund_fee"`
+	CouponRefundCount   int    `xml:"coupon_refund_count"`
+
+	CouponRefunds []CouponRefund `xml:"-"` // 代金券退款明细(coupon_refund_*_$n)
 }
 
 func (this *RefundResponse) IsSuccess() bool {
@@ -59,59 +75,101 @@ func (this *RefundResponse) IsSuccess() bool {
 }
 
 func (this *RefundPayload) PreSignCheck() (err error) {
-	if this.AppID == "" {
-		err = errors.New("Missing required parameters: appid")
+	v := &ValidationError{}
+	v.require("appid", this.AppID != "")
+	v.require("total_fee", this.TotalFee != 0)
+	v.require("refund_fee", this.RefundFee != 0)
+	v.check("total_fee", this.TotalFee >= 0 && this.TotalFee <= MaxTotalFee)
+	v.check("refund_fee", this.RefundFee >= 0 && this.RefundFee <= this.TotalFee)
+	v.check("refund_fee", this.SettlementTotalFee == 0 || this.RefundFee <= this.SettlementTotalFee)
+	v.check("refund_account", this.RefundAccount == "" ||
+		this.RefundAccount == RefundSourceUnsettledFunds || this.RefundAccount == RefundSourceRechargeFunds)
+	return v.err()
+}
+
+func Refund(payload *RefundPayload, secretKey string, cert string, key string) (response RefundResponse, err error) {
+	return RefundWithContext(context.Background(), payload, secretKey, cert, key)
+}
+
+func RefundWithContext(ctx context.Context, payload *RefundPayload, secretKey string, cert string, key string) (response RefundResponse, err error) {
+	tlsCert, err := LoadCertFromFile(cert, key)
+	if err != nil {
 		return
 	}
-	return
+	return RefundWithClient(ctx, newCertClient(tlsCert), payload, secretKey)
 }
 
-func Refund(payload *RefundPayload, secretKey string, cert string, key string) (response RefundResponse, err error) {
+// RefundWithClient 使用调用方提供的HTTP客户端申请退款, c的TLS配置须含商户API证书(见NewTLSClient)
+func RefundWithClient(ctx context.Context, c *http.Client, payload *RefundPayload, secretKey string) (response RefundResponse, err error) {
 	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
 		err = preSignErr
 		return
 	}
-	bs, _ := json.Marshal(payload)
-	pm := make(map[string]interface{})
-	if err1 := json.Unmarshal(bs, &pm); err1 != nil {
-		err = err1
+	if err = CheckSecretKey(secretKey); err != nil {
 		return
 	}
-	sign := Sign(pm, secretKey)
-	payload.Sign = sign
-	XML, _ := xml.Marshal(payload)
-	req, err2 := http.NewRequest(
-		"POST",
-		RefundURL,
-		bytes.NewReader(XML))
-	if err2 != nil {
-		err = err2
+	body, err1 := doXMLRequest(ctx, c, RefundURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
 		return
 	}
-	req.Header.Set("Accept", "application/xml")
-	req.Header.Set("Content-Type", "application/xml;charset=utf-8")
-	c := http.Client{}
-	tlsConfig, err := NewTLSConfig(cert, key)
-	if err != nil {
+	params, err4 := XMLToMap(body)
+	if err4 != nil {
+		err = err4
 		return
 	}
-	c.Transport = &http.Transport{TLSClientConfig: tlsConfig}
-	resp, err3 := c.Do(req)
-	if err3 != nil {
-		err = err3
+	response.CouponRefunds = parseCouponRefunds(params, "")
+	return
+}
+
+type RefundResult struct {
+	OutRefundNo string         // 商户退款单号
+	Response    RefundResponse // 退款结果
+	Err         error          // 退款失败原因
+}
+
+// RefundBatch 以concurrency个并发批量退款, 共用同一商户证书客户端.
+// 结果与reqs按下标一一对应; ctx取消后未发出的退款记为ctx.Err(), 并作为err返回
+func RefundBatch(ctx context.Context, reqs []RefundPayload, secretKey string, cert tls.Certificate, concurrency int) (results []RefundResult, err error) {
+	if err = requireCert(cert); err != nil {
 		return
 	}
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	fmt.Println(string(body))
-	response = RefundResponse{}
-	if err4 := xml.Unmarshal(body, &response); err4 != nil {
-		err = err4
-		return
+	return RefundBatchWithClient(ctx, newCertClient(cert), reqs, secretKey, concurrency)
+}
+
+// RefundBatchWithClient 同RefundBatch, 使用调用方提供的HTTP客户端, c的TLS配置须含商户API证书
+func RefundBatchWithClient(ctx context.Context, c *http.Client, reqs []RefundPayload, secretKey string, concurrency int) (results []RefundResult, err error) {
+	if concurrency < 1 {
+		concurrency = 1
 	}
-	if !response.IsSuccess() {
-		err = errors.New(response.ErrCodeDes)
-		return
+	results = make([]RefundResult, len(reqs))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				payload := reqs[i]
+				results[i].OutRefundNo = payload.OutRefundNo
+				results[i].Response, results[i].Err = RefundWithClient(ctx, c, &payload, secretKey)
+			}
+		}()
+	}
+feed:
+	for i := range reqs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(reqs); j++ {
+				results[j].OutRefundNo = reqs[j].OutRefundNo
+				results[j].Err = ctx.Err()
+			}
+			break feed
+		}
 	}
+	close(jobs)
+	wg.Wait()
+	err = ctx.Err()
 	return
 }
@@ -0,0 +1,141 @@
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRefundPayload() *RefundPayload {
+	return &RefundPayload{
+		AppID:       "wx123",
+		MchID:       "10000100",
+		NonceStr:    "n1",
+		OutTradeNo:  "T1",
+		OutRefundNo: "R1",
+		TotalFee:    100,
+		RefundFee:   100,
+		OpUserID:    "10000100",
+	}
+}
+
+func TestRefundRequiresCert(t *testing.T) {
+	for _, paths := range [][2]string{{"", ""}, {"apiclient_cert.pem", ""}, {"", "apiclient_key.pem"}} {
+		if _, err := Refund(testRefundPayload(), testSecretKey, paths[0], paths[1]); err != ErrCertificateRequired {
+			t.Fatalf("Refund(%q, %q) err = %v, want ErrCertificateRequired", paths[0], paths[1], err)
+		}
+	}
+}
+
+func TestRefundBatchRequiresCert(t *testing.T) {
+	_, err := RefundBatch(context.Background(), []RefundPayload{*testRefundPayload()}, testSecretKey, tls.Certificate{}, 1)
+	if err != ErrCertificateRequired {
+		t.Fatalf("err = %v, want ErrCertificateRequired", err)
+	}
+}
+
+func TestGetPublicKeyRequiresCert(t *testing.T) {
+	if _, err := GetPublicKey("10000100", testSecretKey, "", ""); err != ErrCertificateRequired {
+		t.Fatalf("err = %v, want ErrCertificateRequired", err)
+	}
+}
+
+// refundTwoCouponsRespXML 退还两张代金券的申请退款响应
+const refundTwoCouponsRespXML = `<xml>
+  <return_code><![CDATA[SUCCESS]]></return_code>
+  <return_msg><![CDATA[OK]]></return_msg>
+  <appid><![CDATA[wx123]]></appid>
+  <mch_id><![CDATA[10000100]]></mch_id>
+  <nonce_str><![CDATA[NfsMFbUFpdbEhPXP]]></nonce_str>
+  <result_code><![CDATA[SUCCESS]]></result_code>
+  <transaction_id><![CDATA[1008450740201411110005820873]]></transaction_id>
+  <out_trade_no><![CDATA[T1]]></out_trade_no>
+  <out_refund_no><![CDATA[R1]]></out_refund_no>
+  <refund_id><![CDATA[2008450740201411110000174436]]></refund_id>
+  <refund_fee>100</refund_fee>
+  <total_fee>100</total_fee>
+  <cash_fee>70</cash_fee>
+  <cash_refund_fee>70</cash_refund_fee>
+  <coupon_refund_fee>30</coupon_refund_fee>
+  <coupon_refund_count>2</coupon_refund_count>
+  <coupon_refund_id_0><![CDATA[2000000000001]]></coupon_refund_id_0>
+  <coupon_type_0><![CDATA[CASH]]></coupon_type_0>
+  <coupon_refund_fee_0>20</coupon_refund_fee_0>
+  <coupon_refund_id_1><![CDATA[2000000000002]]></coupon_refund_id_1>
+  <coupon_type_1><![CDATA[NO_CASH]]></coupon_type_1>
+  <coupon_refund_fee_1>10</coupon_refund_fee_1>
+</xml>`
+
+func TestRefundParsesCouponRefunds(t *testing.T) {
+	server := newXMLServer(t, http.StatusOK, refundTwoCouponsRespXML, nil)
+	response, err := RefundWithClient(context.Background(), redirectClient(server), testRefundPayload(), testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CouponRefund{
+		{CouponRefundID: "2000000000001", CouponType: CouponTypeCash, CouponRefundFee: 20},
+		{CouponRefundID: "2000000000002", CouponType: CouponTypeNoCash, CouponRefundFee: 10},
+	}
+	if response.CouponRefundFee != 30 || response.CouponRefundCount != 2 || len(response.CouponRefunds) != len(want) {
+		t.Fatalf("response = %+v", response)
+	}
+	for i := range want {
+		if response.CouponRefunds[i] != want[i] {
+			t.Errorf("coupon refund %d = %+v, want %+v", i, response.CouponRefunds[i], want[i])
+		}
+	}
+}
+
+func TestRefundSettlementTotalFee(t *testing.T) {
+	// 订单1000分, 使用300分非充值代金券, 应结订单金额700分
+	payload := testRefundPayload()
+	payload.TotalFee = 1000
+	payload.SettlementTotalFee = 700
+	payload.RefundFee = 700
+	if err := payload.PreSignCheck(); err != nil {
+		t.Fatalf("refund of settlement_total_fee rejected: %v", err)
+	}
+	payload.RefundFee = 701
+	if err := payload.PreSignCheck(); err == nil || !strings.Contains(err.Error(), "refund_fee") {
+		t.Fatalf("refund above settlement_total_fee: err = %v, want refund_fee error", err)
+	}
+	pm, err := toParams(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pm["settlement_total_fee"]; ok {
+		t.Fatal("settlement_total_fee must not be sent")
+	}
+	payload.SettlementTotalFee = 0
+	if err := payload.PreSignCheck(); err != nil {
+		t.Fatalf("refund without settlement_total_fee: %v", err)
+	}
+}
+
+// TestPayloadTagsWellFormed json与xml标签须可被解析且参数名一致: 标签格式错误时(如引号内多余空格)
+// omitempty可能失效, 空值参与签名或出现在请求XML中
+func TestPayloadTagsWellFormed(t *testing.T) {
+	payloads := []interface{}{
+		RefundPayload{}, UnifiedOrderPayload{}, OrderQueryPayload{}, RefundQueryPayload{}, MicroPayPayload{},
+		ReversePayload{}, ReportPayload{}, GetPublicKeyPayload{}, ProfitSharingReceiverPayload{},
+		ProfitSharingFinishPayload{}, ProfitSharingAmountQueryPayload{}, ProfitSharingReturnPayload{},
+	}
+	for _, payload := range payloads {
+		typ := reflect.TypeOf(payload)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			jsonTag, jsonOK := field.Tag.Lookup("json")
+			xmlTag, xmlOK := field.Tag.Lookup("xml")
+			if !jsonOK || !xmlOK || strings.ContainsAny(jsonTag+xmlTag, " \t") {
+				t.Errorf("%s.%s: malformed tag %q", typ.Name(), field.Name, field.Tag)
+				continue
+			}
+			if jsonName := strings.Split(jsonTag, ",")[0]; jsonName != "-" && jsonName != strings.Split(xmlTag, ",")[0] {
+				t.Errorf("%s.%s: json name %q differs from xml tag %q", typ.Name(), field.Name, jsonName, xmlTag)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+	微信退款结果通知
+*/
+
+package weixin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+var ErrDecryptFailed = errors.New("Failed to decrypt refund notify req_info, check that the API secret key is correct")
+
+// ErrRefundNotifyFailed 退款结果通知的return_code非SUCCESS, 通知不含req_info, 不能作为退款结果
+var ErrRefundNotifyFailed = errors.New("Refund notify return_code is not SUCCESS")
+
+type RefundNotify struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	AppId      string `xml:"appid"`
+	MchId      string `xml:"mch_id"`
+	NonceStr   string `xml:"nonce_str"`
+	ReqInfo    string `xml:"req_info"` // 加密信息, 解密结果见Info
+
+	Info RefundNotifyInfo `xml:"-"`
+}
+
+type RefundNotifyInfo struct {
+	TransactionId       string `xml:"transaction_id"`
+	OutTradeNo          string `xml:"out_trade_no"`
+	RefundId            string `xml:"refund_id"`
+	OutRefundNo         string `xml:"out_refund_no"`
+	TotalFee            int    `xml:"total_fee"`
+	SettlementTotalFee  int    `xml:"settlement_total_fee"`
+	RefundFee           int    `xml:"refund_fee"`
+	SettlementRefundFee int    `xml:"settlement_refund_fee"`
+	RefundStatus        string `xml:"refund_status"` // SUCCESS/CHANGE/REFUNDCLOSE
+	SuccessTime         string `xml:"success_time"`
+	RefundRecvAccout    string `xml:"refund_recv_accout"`
+	RefundAccount       string `xml:"refund_account"`
+	RefundRequestSource string `xml:"refund_request_source"`
+}
+
+func (this *RefundNotify) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS"
+}
+
+// ParseRefundNotify 解析退款结果通知并解密req_info, return_code非SUCCESS时返回ErrRefundNotifyFailed.
+// 退款结果通知不含sign, 以req_info能否解密校验来源. 解密密钥为API密钥的32位小写md5,
+// req_info无法解密或解密结果不是合法XML时返回ErrDecryptFailed
+func ParseRefundNotify(body []byte, secretKey string) (notify *RefundNotify, err error) {
+	body = trimXMLBody(body)
+	notify = &RefundNotify{}
+	if err = xml.Unmarshal(body, notify); err != nil {
+		notify = nil
+		return
+	}
+	if !notify.IsSuccess() {
+		err = fmt.Errorf("%w: %s", ErrRefundNotifyFailed, notify.ReturnMsg)
+		notify = nil
+		return
+	}
+	plain, err := decryptReqInfo(notify.ReqInfo, secretKey)
+	if err != nil {
+		notify = nil
+		return
+	}
+	if err = xml.Unmarshal(plain, &notify.Info); err != nil {
+		notify = nil
+		err = ErrDecryptFailed
+	}
+	return
+}
+
+// decryptReqInfo AES-256-ECB解密, PKCS#7填充
+func decryptReqInfo(reqInfo string, secretKey string) (plain []byte, err error) {
+	cipherText, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		err = ErrDecryptFailed
+		return
+	}
+	key := fmt.Sprintf("%x", md5.Sum([]byte(secretKey)))
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return
+	}
+	size := block.BlockSize()
+	if len(cipherText) == 0 || len(cipherText)%size != 0 {
+		err = ErrDecryptFailed
+		return
+	}
+	plain = make([]byte, len(cipherText))
+	for i := 0; i < len(cipherText); i += size {
+		block.Decrypt(plain[i:i+size], cipherText[i:i+size])
+	}
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > size || !bytes.Equal(plain[len(plain)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		plain = nil
+		err = ErrDecryptFailed
+		return
+	}
+	plain = plain[:len(plain)-padding]
+	if !bytes.HasPrefix(bytes.TrimSpace(plain), []byte("<")) {
+		plain = nil
+		err = ErrDecryptFailed
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package weixin
+
+import (
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// encryptBlocks 以API密钥的md5按AES-256-ECB加密data, 不做填充, data长度须为16的倍数
+func encryptBlocks(data []byte, secretKey string) string {
+	block, _ := aes.NewCipher([]byte(fmt.Sprintf("%x", md5.Sum([]byte(secretKey)))))
+	out := make([]byte, len(data))
+	for i := 0; i < len(data); i += block.BlockSize() {
+		block.Encrypt(out[i:i+block.BlockSize()], data[i:i+block.BlockSize()])
+	}
+	return base64.StdEncoding.EncodeToString(out)
+}
+
+func TestDecryptReqInfo(t *testing.T) {
+	plain := "<root><out_refund_no>R1</out_refund_no></root>"
+	got, err := decryptReqInfo(encryptReqInfo(plain, testSecretKey), testSecretKey)
+	if err != nil || string(got) != plain {
+		t.Fatalf("plain = %q, err = %v", got, err)
+	}
+	cases := map[string]string{
+		"wrong key":              encryptReqInfo(plain, "98765432109876543210987654321098"),
+		"not block size":         base64.StdEncoding.EncodeToString(make([]byte, 20)),
+		"empty":                  "",
+		"not base64":             "req_info!",
+		"zero padding":           encryptBlocks([]byte("<root></root>\x00\x00\x00"), testSecretKey),
+		"inconsistent padding":   encryptBlocks([]byte("<root></root>\x01\x02\x03"), testSecretKey),
+		"padding over one block": encryptBlocks([]byte("<root></root>\x11\x11\x11"), testSecretKey),
+	}
+	for name, reqInfo := range cases {
+		if _, err := decryptReqInfo(reqInfo, testSecretKey); !errors.Is(err, ErrDecryptFailed) {
+			t.Errorf("%s: err = %v, want ErrDecryptFailed", name, err)
+		}
+	}
+}
+
+func TestParseRefundNotifyWrongKey(t *testing.T) {
+	_, err := ParseRefundNotify([]byte(testRefundNotifyXML()), "98765432109876543210987654321098")
+	if !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("err = %v, want ErrDecryptFailed", err)
+	}
+}
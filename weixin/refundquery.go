@@ -0,0 +1,165 @@
+/*
+	微信查询退款API
+*/
+
+package weixin
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+)
+
+const (
+	RefundQueryURL string = "https://api.mch.weixin.qq.com/pay/refundquery"
+)
+
+type RefundQueryPayload struct {
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	AppId         string   `json:"appid,omitempty" xml:"appid,omitempty"`                   // R. 应用ID
+	MchId         string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                 // R. 商户号
+	NonceStr      string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`           // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                     // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`           // O. 签名类型,默认MD5
+	TransactionId string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"` // C. 微信订单号, 四选一
+	OutTradeNo    string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`     // C. 商户订单号
+	OutRefundNo   string   `json:"out_refund_no,omitempty" xml:"out_refund_no,omitempty"`   // C. 商户退款单号
+	RefundId      string   `json:"refund_id,omitempty" xml:"refund_id,omitempty"`           // C. 微信退款单号
+	Offset        *int     `json:"offset,omitempty" xml:"offset,omitempty"`                 // O. 偏移量, 订单退款超过10笔时分页查询, 非nil时传递(含0)
+}
+
+func (this *RefundQueryPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+func (this *RefundQueryPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("nonce_str", this.NonceStr != "")
+	v.require("transaction_id/out_trade_no/out_refund_no/refund_id",
+		this.TransactionId != "" || this.OutTradeNo != "" || this.OutRefundNo != "" || this.RefundId != "")
+	v.check("offset", this.Offset == nil || *this.Offset >= 0)
+	return v.err()
+}
+
+type RefundQueryResp struct {
+	ReturnCode         string `xml:"return_code"`
+	ReturnMsg          string `xml:"return_msg"`
+	ResultCode         string `xml:"result_code"`
+	ErrCode            string `xml:"err_code"`
+	ErrCodeDes         string `xml:"err_code_des"`
+	AppId              string `xml:"appid"`
+	MchId              string `xml:"mch_id"`
+	NonceStr           string `xml:"nonce_str"`
+	Sign               string `xml:"sign"`
+	TotalRefundCount   int    `xml:"total_refund_count"` // 订单总退款次数, 仅分页查询时返回
+	TransactionId      string `xml:"transaction_id"`
+	OutTradeNo         string `xml:"out_trade_no"`
+	TotalFee           int    `xml:"total_fee"`
+	SettlementTotalFee int    `xml:"settlement_total_fee"`
+	FeeType            string `xml:"fee_type"`
+	CashFee            int    `xml:"cash_fee"`
+	RefundCount        int    `xml:"refund_count"` // 本次返回的退款笔数
+
+	Refunds []RefundQueryItem `xml:"-"` // 退款明细(*_$n)
+}
+
+type RefundQueryItem struct {
+	OutRefundNo       string // 商户退款单号
+	RefundId          string // 微信退款单号
+	RefundChannel     string // 退款渠道
+	RefundFee         int    // 申请退款金额(分)
+	RefundStatus      string // 退款状态: SUCCESS/REFUNDCLOSE/PROCESSING/CHANGE
+	RefundAccount     string // 退款资金来源
+	RefundRecvAccout  string // 退款入账账户
+	RefundSuccessTime string // 退款成功时间
+	CouponRefundFee   int    // 代金券退款总金额(分)
+
+	CouponRefunds []CouponRefund // 代金券退款明细(*_$n_$m)
+}
+
+func (this *RefundQueryResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func parseRefundQueryItems(body []byte) (items []RefundQueryItem, err error) {
+	params, err := XMLToMap(body)
+	if err != nil {
+		return
+	}
+	count, _ := strconv.Atoi(params["refund_count"])
+	for n := 0; n < count; n++ {
+		idx := strconv.Itoa(n)
+		fee, _ := strconv.Atoi(params["refund_fee_"+idx])
+		couponFee, _ := strconv.Atoi(params["coupon_refund_fee_"+idx])
+		items = append(items, RefundQueryItem{
+			OutRefundNo:       params["out_refund_no_"+idx],
+			RefundId:          params["refund_id_"+idx],
+			RefundChannel:     params["refund_channel_"+idx],
+			RefundFee:         fee,
+			RefundStatus:      params["refund_status_"+idx],
+			RefundAccount:     params["refund_account_"+idx],
+			RefundRecvAccout:  params["refund_recv_accout_"+idx],
+			RefundSuccessTime: params["refund_success_time_"+idx],
+			CouponRefundFee:   couponFee,
+			CouponRefunds:     parseCouponRefunds(params, "_"+idx),
+		})
+	}
+	return
+}
+
+func RefundQuery(payload *RefundQueryPayload, secretKey string) (response RefundQueryResp, err error) {
+	return RefundQueryWithContext(context.Background(), payload, secretKey)
+}
+
+func RefundQueryWithContext(ctx context.Context, payload *RefundQueryPayload, secretKey string) (response RefundQueryResp, err error) {
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	c := &http.Client{Timeout: DefaultTimeout}
+	body, err1 := doXMLRequest(ctx, c, RefundQueryURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+		return
+	}
+	response.Refunds, err = parseRefundQueryItems(body)
+	return
+}
+
+func QueryAllRefunds(appId, mchId, outTradeNo, secretKey string) ([]RefundQueryItem, error) {
+	return QueryAllRefundsWithContext(context.Background(), appId, mchId, outTradeNo, secretKey)
+}
+
+// QueryAllRefundsWithContext 按offset分页查询订单的全部退款记录, 直至取满total_refund_count
+func QueryAllRefundsWithContext(ctx context.Context, appId, mchId, outTradeNo, secretKey string) (refunds []RefundQueryItem, err error) {
+	offset := 0
+	for {
+		response, queryErr := RefundQueryWithContext(ctx, refundQueryPage(appId, mchId, outTradeNo, offset), secretKey)
+		if queryErr != nil {
+			err = queryErr
+			return
+		}
+		refunds = append(refunds, response.Refunds...)
+		if response.RefundCount == 0 || len(refunds) >= response.TotalRefundCount {
+			return
+		}
+		offset += len(response.Refunds)
+	}
+}
+
+// refundQueryPage 分页查询的请求, 首页也传offset=0, 否则微信不返回total_refund_count
+func refundQueryPage(appId, mchId, outTradeNo string, offset int) *RefundQueryPayload {
+	return &RefundQueryPayload{
+		AppId:      appId,
+		MchId:      mchId,
+		NonceStr:   NonceStr(),
+		OutTradeNo: outTradeNo,
+		Offset:     &offset,
+	}
+}
@@ -0,0 +1,53 @@
+package weixin
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestRefundQueryPageSendsZeroOffset(t *testing.T) {
+	payload := refundQueryPage("wx123", "10000100", "T1", 0)
+	pm, err := toParams(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := CanonicalString(pm); !strings.Contains(str, "offset=0") {
+		t.Fatalf("sign string %q lacks offset=0", str)
+	}
+	XML, _ := xml.Marshal(payload)
+	if !strings.Contains(string(XML), "<offset>0</offset>") {
+		t.Fatalf("request %s lacks <offset>0</offset>", XML)
+	}
+}
+
+func TestRefundQueryOmitsNilOffset(t *testing.T) {
+	pm, _ := toParams(&RefundQueryPayload{AppId: "wx123", OutTradeNo: "T1"})
+	if _, ok := pm["offset"]; ok {
+		t.Fatalf("offset sent without being set: %v", pm)
+	}
+}
+
+func TestParseRefundQueryItemsCouponRefunds(t *testing.T) {
+	body := []byte(`<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><refund_count>1</refund_count>` +
+		`<out_refund_no_0>R1</out_refund_no_0><refund_id_0>2008450740201411110000174436</refund_id_0><refund_fee_0>100</refund_fee_0>` +
+		`<refund_status_0>SUCCESS</refund_status_0><coupon_refund_fee_0>30</coupon_refund_fee_0><coupon_refund_count_0>2</coupon_refund_count_0>` +
+		`<coupon_refund_id_0_0>2000000000001</coupon_refund_id_0_0><coupon_type_0_0>CASH</coupon_type_0_0><coupon_refund_fee_0_0>20</coupon_refund_fee_0_0>` +
+		`<coupon_refund_id_0_1>2000000000002</coupon_refund_id_0_1><coupon_type_0_1>NO_CASH</coupon_type_0_1><coupon_refund_fee_0_1>10</coupon_refund_fee_0_1></xml>`)
+	items, err := parseRefundQueryItems(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].CouponRefundFee != 30 || len(items[0].CouponRefunds) != 2 {
+		t.Fatalf("items = %+v", items)
+	}
+	want := []CouponRefund{
+		{CouponRefundID: "2000000000001", CouponType: CouponTypeCash, CouponRefundFee: 20},
+		{CouponRefundID: "2000000000002", CouponType: CouponTypeNoCash, CouponRefundFee: 10},
+	}
+	for i := range want {
+		if items[0].CouponRefunds[i] != want[i] {
+			t.Errorf("coupon refund %d = %+v, want %+v", i, items[0].CouponRefunds[i], want[i])
+		}
+	}
+}
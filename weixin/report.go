@@ -0,0 +1,86 @@
+/*
+	微信交易保障(接口上报)API
+*/
+
+package weixin
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+const (
+	ReportURL string = "https://api.mch.weixin.qq.com/payitil/report"
+)
+
+type ReportPayload struct {
+	XMLName      xml.Name `xml:"xml" json:"-"`
+	AppId        string   `json:"appid,omitempty" xml:"appid,omitempty"`                 // R. 应用ID
+	MchId        string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`               // R. 商户号
+	DeviceInfo   string   `json:"device_info,omitempty" xml:"device_info,omitempty"`     // O. 设备号
+	NonceStr     string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`         // R. 随机字符串
+	Sign         string   `json:"sign,omitempty" xml:"sign,omitempty"`                   // R. 签名
+	SignType     string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`         // O. 签名类型,默认MD5
+	InterfaceURL string   `json:"interface_url,omitempty" xml:"interface_url,omitempty"` // R. 上报的接口URL
+	ExecuteTime  int64    `json:"execute_time" xml:"execute_time"`                       // R. 接口耗时(毫秒), 由Report计算, 0毫秒也须上报
+	ReturnCode   string   `json:"return_code,omitempty" xml:"return_code,omitempty"`     // R. 被上报接口的返回状态码
+	ReturnMsg    string   `json:"return_msg,omitempty" xml:"return_msg,omitempty"`       // O. 被上报接口的返回信息
+	ResultCode   string   `json:"result_code,omitempty" xml:"result_code,omitempty"`     // R. 被上报接口的业务结果
+	ErrCode      string   `json:"err_code,omitempty" xml:"err_code,omitempty"`           // O. 错误代码
+	ErrCodeDes   string   `json:"err_code_des,omitempty" xml:"err_code_des,omitempty"`   // O. 错误代码描述
+	OutTradeNo   string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`   // O. 商户订单号
+	UserIp       string   `json:"user_ip,omitempty" xml:"user_ip,omitempty"`             // R. 发起接口调用的机器IP
+	Time         string   `json:"time,omitempty" xml:"time,omitempty"`                   // O. 商户上报时间(yyyyMMddHHmmss), 由Report填写
+}
+
+func (this *ReportPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+func (this *ReportPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("nonce_str", this.NonceStr != "")
+	v.require("interface_url", this.InterfaceURL != "")
+	v.require("return_code", this.ReturnCode != "")
+	v.require("result_code", this.ResultCode != "")
+	v.require("user_ip", this.UserIp != "")
+	return v.err()
+}
+
+type ReportResp struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ResultCode string `xml:"result_code"`
+}
+
+func (this *ReportResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+// 上报接口的响应不含sign
+func (this *ReportResp) unsigned() {}
+
+func Report(payload *ReportPayload, start time.Time, secretKey string) (response ReportResp, err error) {
+	return ReportWithContext(context.Background(), payload, start, secretKey)
+}
+
+// ReportWithContext 上报接口调用耗时, start为被上报接口的调用开始时间.
+// execute_time按time.Since(start)计算, time填写为当前北京时间
+func ReportWithContext(ctx context.Context, payload *ReportPayload, start time.Time, secretKey string) (response ReportResp, err error) {
+	payload.ExecuteTime = time.Since(start).Milliseconds()
+	payload.Time = time.Now().In(ChinaLocation).Format(ChinaTimeLayout)
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	c := &http.Client{Timeout: DefaultTimeout}
+	_, err = doXMLRequest(ctx, c, ReportURL, payload, secretKey, &response)
+	return
+}
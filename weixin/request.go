@@ -0,0 +1,119 @@
+package weixin
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// postXML 发送XML请求并读取响应报文, 响应体在返回前关闭, 各接口均应经此发送请求以免连接泄漏
+func postXML(ctx context.Context, c *http.Client, url string, XML []byte) (body []byte, header http.Header, err error) {
+	debugRequestXML(XML)
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		url,
+		bytes.NewReader(XML))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("Content-Type", "application/xml;charset=utf-8")
+	resp, err := c.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	header = resp.Header
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return
+	}
+	body = trimXMLBody(body)
+	debugln(string(body))
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Unexpected HTTP status: %s", resp.Status)
+	}
+	return
+}
+
+type xmlResponse interface {
+	IsSuccess() bool
+}
+
+// unsignedResponse 响应不含sign的接口(如交易保障上报), doXMLRequest不对其验签
+type unsignedResponse interface {
+	unsigned()
+}
+
+// signedPayload 请求参数, signFields返回其Sign及SignType字段
+type signedPayload interface {
+	signFields() (sign, signType *string)
+}
+
+// signPayload 清空payload中的旧签名后按其SignType签名并编码为XML. SignType为空且接口默认
+// 非MD5时写入接口默认签名类型(见resolveSignType), 使sign_type参与签名并出现在请求XML中;
+// MD5为微信缺省签名类型, 不写入.
+// 请求参数取自payload的json标签, payload实现signParams时(如含ExtraParams)以其结果签名;
+// XML按结构体字段顺序输出
+func signPayload(url string, payload signedPayload, secretKey string) (XML []byte, err error) {
+	sign, signType := payload.signFields()
+	*sign = ""
+	if resolved := resolveSignType(*signType, url); resolved != SignTypeMD5 {
+		*signType = resolved
+	}
+	var pm map[string]interface{}
+	if p, ok := payload.(interface {
+		signParams() (map[string]interface{}, error)
+	}); ok {
+		pm, err = p.signParams()
+	} else {
+		pm, err = toParams(payload)
+	}
+	if err != nil {
+		return
+	}
+	*sign = SignWithType(pm, secretKey, resolveSignType(*signType, url))
+	return xml.Marshal(payload)
+}
+
+// doXMLRequest 签名payload并POST至url(见signPayload), 非200状态返回错误, 去除响应BOM后
+// 按VerifyResponses校验响应签名, 将响应解析至out, return_code/result_code非SUCCESS时返回*WxPayError.
+// 返回响应报文, 用于解析*_$n等下标字段. 各接口均应经此发送请求, 调用前完成参数检查
+func doXMLRequest(ctx context.Context, c *http.Client, url string, payload signedPayload, secretKey string, out xmlResponse) (body []byte, err error) {
+	XML, err := signPayload(url, payload, secretKey)
+	if err != nil {
+		return
+	}
+	body, header, err := postXML(ctx, c, url, XML)
+	if err != nil {
+		return
+	}
+	if _, unsigned := out.(unsignedResponse); VerifyResponses && !unsigned {
+		_, signType := payload.signFields()
+		if err = VerifyResponseSign(body, secretKey, resolveSignType(*signType, url)); err != nil {
+			return
+		}
+	}
+	if err = xml.Unmarshal(body, out); err != nil {
+		return
+	}
+	if !out.IsSuccess() {
+		err = newWxPayError(body, header)
+	}
+	return
+}
+
+// newWxPayError 由响应报文构造WxPayError
+func newWxPayError(body []byte, header http.Header) *WxPayError {
+	params, _ := XMLToMap(body)
+	return &WxPayError{
+		ReturnCode: params["return_code"],
+		ReturnMsg:  params["return_msg"],
+		ErrCode:    params["err_code"],
+		ErrCodeDes: params["err_code_des"],
+		Headers:    header,
+	}
+}
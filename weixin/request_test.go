@@ -0,0 +1,218 @@
+package weixin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testSecretKey = "01234567890123456789012345678901"
+
+// signedXML 按testSecretKey签名params并返回<xml>报文
+func signedXML(params map[string]string) string {
+	pm := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		pm[k] = v
+	}
+	XML := "<xml>"
+	for k, v := range params {
+		XML += "<" + k + ">" + v + "</" + k + ">"
+	}
+	return XML + "<sign>" + Sign(pm, testSecretKey) + "</sign></xml>"
+}
+
+func newXMLServer(t *testing.T, status int, response string, requests *[]map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		params, err := XMLToMap(body)
+		if err != nil {
+			t.Errorf("request is not XML: %v", err)
+		}
+		if requests != nil {
+			*requests = append(*requests, params)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// redirectClient 将请求转发至server的HTTP客户端, 用于测试使用固定接口URL的XxxWithClient函数
+func redirectClient(server *httptest.Server) *http.Client {
+	target, _ := url.Parse(server.URL)
+	return &http.Client{Transport: redirectTransport{target}}
+}
+
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (this redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = this.target.Scheme, this.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// recordTransport 记录请求报文后交由next发送
+type recordTransport struct {
+	next   http.RoundTripper
+	bodies *[]string
+}
+
+func (this recordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	*this.bodies = append(*this.bodies, string(body))
+	r = r.Clone(r.Context())
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return this.next.RoundTrip(r)
+}
+
+func testOrderQueryPayload() *OrderQueryPayload {
+	return &OrderQueryPayload{AppId: "wx123", MchId: "10000100", OutTradeNo: "T1", NonceStr: "n1"}
+}
+
+func TestDoXMLRequestSignsPayload(t *testing.T) {
+	var requests []map[string]string
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>", &requests)
+	payload := testOrderQueryPayload()
+	payload.Sign = "STALE"
+	for i := 0; i < 2; i++ {
+		response := OrderQueryResp{}
+		if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := Sign(map[string]interface{}{"appid": "wx123", "mch_id": "10000100", "out_trade_no": "T1", "nonce_str": "n1"}, testSecretKey)
+	for _, params := range requests {
+		if params["sign"] != want {
+			t.Fatalf("sign = %s, want %s", params["sign"], want)
+		}
+	}
+	if payload.Sign != want {
+		t.Fatalf("payload.Sign = %s, want %s", payload.Sign, want)
+	}
+}
+
+func TestDoXMLRequestTrimsBOM(t *testing.T) {
+	server := newXMLServer(t, http.StatusOK, "\xef\xbb\xbf\n<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><trade_state>SUCCESS</trade_state></xml>", nil)
+	payload := testOrderQueryPayload()
+	response := OrderQueryResp{}
+	if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.TradeState != TradeStateSuccess {
+		t.Fatalf("trade_state = %q", response.TradeState)
+	}
+}
+
+func TestDoXMLRequestRejectsNon200(t *testing.T) {
+	server := newXMLServer(t, http.StatusBadGateway, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>", nil)
+	payload := testOrderQueryPayload()
+	response := OrderQueryResp{}
+	if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err == nil {
+		t.Fatal("expected error for HTTP 502")
+	}
+}
+
+func TestDoXMLRequestReturnsWxPayError(t *testing.T) {
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>ORDERNOTEXIST</err_code></xml>", nil)
+	payload := testOrderQueryPayload()
+	response := OrderQueryResp{}
+	_, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response)
+	var wxErr *WxPayError
+	if !errors.As(err, &wxErr) || wxErr.ErrCode != ErrCodeOrderNotExist {
+		t.Fatalf("err = %v, want *WxPayError ORDERNOTEXIST", err)
+	}
+}
+
+func TestDoXMLRequestVerifiesResponseSign(t *testing.T) {
+	VerifyResponses = true
+	defer func() { VerifyResponses = false }()
+	params := map[string]string{"return_code": "SUCCESS", "result_code": "SUCCESS", "trade_state": "SUCCESS"}
+	server := newXMLServer(t, http.StatusOK, signedXML(params), nil)
+	payload := testOrderQueryPayload()
+	response := OrderQueryResp{}
+	if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><trade_state>SUCCESS</trade_state><sign>00000000000000000000000000000000</sign></xml>", nil)
+	_, err := doXMLRequest(context.Background(), tampered.Client(), tampered.URL, payload, testSecretKey, &response)
+	var mismatch *SignatureMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("err = %v, want *SignatureMismatchError", err)
+	}
+}
+
+func TestDoXMLRequestSkipsUnsignedResponse(t *testing.T) {
+	VerifyResponses = true
+	defer func() { VerifyResponses = false }()
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code></xml>", nil)
+	payload := &ReportPayload{AppId: "wx123", MchId: "10000100", NonceStr: "n1"}
+	response := ReportResp{}
+	if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignPayloadWritesEndpointSignType(t *testing.T) {
+	payload := &ProfitSharingFinishPayload{MchId: "10000100", AppId: "wx123", NonceStr: "n1", TransactionId: "4200000001", OutOrderNo: "P1", Description: "done"}
+	XML, err := signPayload(ProfitSharingFinishURL, payload, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.SignType != SignTypeHMACSHA256 {
+		t.Fatalf("SignType = %q, want %s", payload.SignType, SignTypeHMACSHA256)
+	}
+	params, err := XMLToMap(XML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["sign_type"] != SignTypeHMACSHA256 {
+		t.Fatalf("sign_type = %q, want %s", params["sign_type"], SignTypeHMACSHA256)
+	}
+	want := SignWithType(map[string]interface{}{
+		"mch_id": "10000100", "appid": "wx123", "nonce_str": "n1", "sign_type": SignTypeHMACSHA256,
+		"transaction_id": "4200000001", "out_order_no": "P1", "description": "done",
+	}, testSecretKey, SignTypeHMACSHA256)
+	if params["sign"] != want {
+		t.Fatalf("sign = %s, want %s", params["sign"], want)
+	}
+
+	query := testOrderQueryPayload()
+	if _, err = signPayload(OrderQueryURL, query, testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+	if query.SignType != "" {
+		t.Fatalf("MD5 endpoint SignType = %q, want empty", query.SignType)
+	}
+}
+
+func TestPayloadsMarshalXMLRoot(t *testing.T) {
+	payloads := []signedPayload{
+		&UnifiedOrderPayload{}, &OrderQueryPayload{}, &RefundPayload{}, &RefundQueryPayload{},
+		&MicroPayPayload{}, &ReversePayload{}, &ReportPayload{}, &GetPublicKeyPayload{},
+		&ProfitSharingReceiverPayload{}, &ProfitSharingFinishPayload{},
+		&ProfitSharingAmountQueryPayload{}, &ProfitSharingReturnPayload{},
+	}
+	for _, payload := range payloads {
+		XML, err := signPayload("", payload, testSecretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(XML), "<xml>") || !strings.HasSuffix(string(XML), "</xml>") {
+			t.Errorf("%T XML = %s, want <xml> root", payload, XML)
+		}
+	}
+}
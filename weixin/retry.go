@@ -0,0 +1,72 @@
+/*
+	统一下单重试
+*/
+
+package weixin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryBackoff 重试间隔, 第n次重试前等待n*RetryBackoff
+var RetryBackoff = 200 * time.Millisecond
+
+// RateLimitBackoff FREQUENCY_LIMITED后重试前的最短等待时间
+var RateLimitBackoff = time.Second
+
+// UnifiedOrderWithRetry 统一下单, 超时、连接被拒绝或重置, 以及SYSTEMERROR、频率限制时最多尝试attempts次.
+// 每次尝试保持out_trade_no不变, 首次尝试沿用调用方设置的nonce_str, 重试时重新生成nonce_str并重新签名
+func UnifiedOrderWithRetry(ctx context.Context, payload *UnifiedOrderPayload, secretKey string, attempts int) (response UnifiedOrderResp, err error) {
+	return UnifiedOrderWithRetryWithClient(ctx, &http.Client{Timeout: DefaultTimeout}, payload, secretKey, attempts)
+}
+
+// UnifiedOrderWithRetryWithClient 同UnifiedOrderWithRetry, 使用调用方提供的HTTP客户端
+func UnifiedOrderWithRetryWithClient(ctx context.Context, c *http.Client, payload *UnifiedOrderPayload, secretKey string, attempts int) (response UnifiedOrderResp, err error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 || payload.NonceStr == "" {
+			payload.NonceStr = NonceStr()
+		}
+		response, err = UnifiedOrderWithClient(ctx, c, payload, secretKey)
+		if err == nil || attempt >= attempts || !isRetriable(response.ErrCode, err) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(retryDelay(attempt, response.ErrCode)):
+		}
+	}
+}
+
+// retryErrCodes 统一下单可使用相同out_trade_no重试的错误码
+var retryErrCodes = map[string]bool{
+	"SYSTEMERROR":           true,
+	ErrCodeFrequencyLimited: true,
+}
+
+// isRetriable 仅超时、连接被拒绝或重置及retryErrCodes可重试, 其它网络错误(如DNS解析失败、证书错误)不重试
+func isRetriable(errCode string, err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	return retryErrCodes[errCode]
+}
+
+// retryDelay 第attempt次尝试失败后的等待时间, 频率限制时不少于RateLimitBackoff
+func retryDelay(attempt int, errCode string) time.Duration {
+	delay := time.Duration(attempt) * RetryBackoff
+	if errCode == ErrCodeFrequencyLimited && delay < RateLimitBackoff {
+		delay = RateLimitBackoff
+	}
+	return delay
+}
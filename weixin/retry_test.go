@@ -0,0 +1,95 @@
+package weixin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFrequencyLimitedIsRetriable(t *testing.T) {
+	if class := ErrCodeClass(ErrCodeFrequencyLimited); class != Retriable {
+		t.Fatalf("FREQUENCY_LIMITED class = %v, want Retriable", class)
+	}
+	if !isRetriable(ErrCodeFrequencyLimited, nil) {
+		t.Fatal("FREQUENCY_LIMITED must be retried")
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	dial := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+	}
+	cases := []struct {
+		name    string
+		errCode string
+		err     error
+		want    bool
+	}{
+		{"timeout", "", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, true},
+		{"connection refused", "", dial(syscall.ECONNREFUSED), true},
+		{"connection reset", "", dial(syscall.ECONNRESET), true},
+		{"dns failure", "", &net.DNSError{Err: "no such host", Name: "api.mch.weixin.qq.com"}, false},
+		{"other net error", "", dial(syscall.EACCES), false},
+		{"SYSTEMERROR", "SYSTEMERROR", &WxPayError{ErrCode: "SYSTEMERROR"}, true},
+		{"FREQUENCY_LIMITED", ErrCodeFrequencyLimited, &WxPayError{ErrCode: ErrCodeFrequencyLimited}, true},
+		{"PARAM_ERROR", "PARAM_ERROR", &WxPayError{ErrCode: "PARAM_ERROR"}, false},
+		{"validation", "", errors.New("Missing required field: appid"), false},
+	}
+	for _, c := range cases {
+		if got := isRetriable(c.errCode, c.err); got != c.want {
+			t.Errorf("%s: isRetriable = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRetryDelayBacksOffOnRateLimit(t *testing.T) {
+	if delay := retryDelay(1, ErrCodeFrequencyLimited); delay < RateLimitBackoff {
+		t.Fatalf("delay = %v, want at least %v", delay, RateLimitBackoff)
+	}
+	if delay := retryDelay(1, "SYSTEMERROR"); delay != RetryBackoff {
+		t.Fatalf("delay = %v, want %v", delay, RetryBackoff)
+	}
+}
+
+func TestUnifiedOrderWithRetryRenewsNonce(t *testing.T) {
+	defer func(backoff time.Duration) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = time.Millisecond
+	var requests []map[string]string
+	responses := []string{
+		"<xml><return_code>SUCCESS</return_code><result_code>FAIL</result_code><err_code>SYSTEMERROR</err_code></xml>",
+		"<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><trade_type>NATIVE</trade_type><code_url>weixin://wxpay/s/1</code_url></xml>",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		params, _ := XMLToMap(body)
+		requests = append(requests, params)
+		w.Write([]byte(responses[len(requests)-1]))
+	}))
+	defer server.Close()
+	payload := testOrder(TradeTypeNative)
+	payload.ProductID = "P1"
+	response, err := UnifiedOrderWithRetryWithClient(context.Background(), redirectClient(server), payload, testSecretKey, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.CodeURL != "weixin://wxpay/s/1" || len(requests) != 2 {
+		t.Fatalf("code_url = %q after %d attempts", response.CodeURL, len(requests))
+	}
+	first, second := requests[0], requests[1]
+	if first["nonce_str"] == second["nonce_str"] {
+		t.Fatalf("retry reused nonce_str %s", first["nonce_str"])
+	}
+	if first["out_trade_no"] != "T1" || second["out_trade_no"] != "T1" {
+		t.Fatalf("out_trade_no = %s, %s, want T1", first["out_trade_no"], second["out_trade_no"])
+	}
+	if first["sign"] == second["sign"] {
+		t.Fatal("retry was not re-signed")
+	}
+}
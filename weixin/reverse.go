@@ -0,0 +1,158 @@
+/*
+	付款码支付撤销订单API及支付结果轮询
+*/
+
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	ReverseURL string = "https://api.mch.weixin.qq.com/secapi/pay/reverse"
+)
+
+// MicroPayPollInterval 付款码支付用户支付中时查询订单的间隔
+var MicroPayPollInterval = 5 * time.Second
+
+// MicroPayPollTimeout 付款码支付等待用户支付的最长时间, 超时后撤销订单
+var MicroPayPollTimeout = 30 * time.Second
+
+// ErrMicroPayReversed 付款码支付在MicroPayPollTimeout内未确认支付成功, 订单已撤销
+var ErrMicroPayReversed = errors.New("Micropay was not confirmed in time and has been reversed")
+
+type ReversePayload struct {
+	XMLName       xml.Name `xml:"xml" json:"-"`
+	AppId         string   `json:"appid,omitempty" xml:"appid,omitempty"`                   // R. 应用ID
+	MchId         string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                 // R. 商户号
+	TransactionId string   `json:"transaction_id,omitempty" xml:"transaction_id,omitempty"` // C. 微信订单号, 与out_trade_no二选一
+	OutTradeNo    string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`     // C. 商户订单号
+	NonceStr      string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`           // R. 随机字符串
+	Sign          string   `json:"sign,omitempty" xml:"sign,omitempty"`                     // R. 签名
+	SignType      string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`           // O. 签名类型,默认MD5
+}
+
+func (this *ReversePayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
+}
+
+func (this *ReversePayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("transaction_id/out_trade_no", this.TransactionId != "" || this.OutTradeNo != "")
+	v.require("nonce_str", this.NonceStr != "")
+	return v.err()
+}
+
+type ReverseResp struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	AppId      string `xml:"appid"`
+	MchId      string `xml:"mch_id"`
+	NonceStr   string `xml:"nonce_str"`
+	Sign       string `xml:"sign"`
+	ResultCode string `xml:"result_code"`
+	ErrCode    string `xml:"err_code"`
+	ErrCodeDes string `xml:"err_code_des"`
+	Recall     string `xml:"recall"` // 是否需要继续调用撤销(Y/N)
+}
+
+func (this *ReverseResp) IsSuccess() bool {
+	return this.ReturnCode == "SUCCESS" && this.ResultCode == "SUCCESS"
+}
+
+func (this *ReverseResp) NeedRecall() bool {
+	return this.Recall == "Y"
+}
+
+func Reverse(payload *ReversePayload, secretKey string, cert tls.Certificate) (response ReverseResp, err error) {
+	return ReverseWithContext(context.Background(), payload, secretKey, cert)
+}
+
+// ReverseWithContext 撤销付款码支付订单, 需要证书. 已支付的订单会退款给用户, 未支付的订单关闭
+func ReverseWithContext(ctx context.Context, payload *ReversePayload, secretKey string, cert tls.Certificate) (response ReverseResp, err error) {
+	if err = requireCert(cert); err != nil {
+		return
+	}
+	return ReverseWithClient(ctx, newCertClient(cert), payload, secretKey)
+}
+
+// ReverseWithClient 使用调用方提供的HTTP客户端撤销订单, c的TLS配置须含商户API证书(见NewTLSClient)
+func ReverseWithClient(ctx context.Context, c *http.Client, payload *ReversePayload, secretKey string) (response ReverseResp, err error) {
+	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
+		err = preSignErr
+		return
+	}
+	if err = CheckSecretKey(secretKey); err != nil {
+		return
+	}
+	_, err1 := doXMLRequest(ctx, c, ReverseURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
+	}
+	return
+}
+
+// PollMicroPay 付款码支付返回USERPAYING后确认支付结果, 按微信推荐流程:
+// 每MicroPayPollInterval查询一次订单, 支付成功或失败时返回查询结果;
+// MicroPayPollTimeout内仍未确认时撤销订单(recall为Y时重新撤销), 撤销完成后返回ErrMicroPayReversed
+func PollMicroPay(ctx context.Context, appId, mchId, outTradeNo, secretKey string, cert tls.Certificate) (response OrderQueryResp, err error) {
+	if err = requireCert(cert); err != nil {
+		return
+	}
+	deadline := time.Now().Add(MicroPayPollTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(MicroPayPollInterval):
+		}
+		response, err = OrderQueryWithContext(ctx, &OrderQueryPayload{
+			AppId:      appId,
+			MchId:      mchId,
+			OutTradeNo: outTradeNo,
+			NonceStr:   NonceStr(),
+		}, secretKey)
+		if err != nil {
+			continue
+		}
+		switch response.TradeState {
+		case TradeStateSuccess:
+			return
+		case TradeStateUserPaying, TradeStateNotPay:
+			continue
+		default:
+			err = errors.New("Micropay failed: " + response.TradeState + " " + response.TradeStateDesc)
+			return
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		reverse, reverseErr := ReverseWithContext(ctx, &ReversePayload{
+			AppId:      appId,
+			MchId:      mchId,
+			OutTradeNo: outTradeNo,
+			NonceStr:   NonceStr(),
+		}, secretKey, cert)
+		if reverseErr == nil {
+			err = ErrMicroPayReversed
+			return
+		}
+		if !reverse.NeedRecall() || attempt >= 10 {
+			err = reverseErr
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(time.Duration(attempt) * RetryBackoff):
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package weixin
+
+import "testing"
+
+// signFixtures 签名样例, 取自微信支付签名算法文档, MD5与HMAC-SHA256结果均与文档一致
+var signFixtures = []struct {
+	name   string
+	params map[string]interface{}
+	md5    string
+	hmac   string
+}{
+	{
+		name: "official example",
+		params: map[string]interface{}{
+			"appid":       "wxd930ea5d5a258f4f",
+			"mch_id":      "10000100",
+			"device_info": "1000",
+			"body":        "test",
+			"nonce_str":   "ibuaiVcKdpRxkhJA",
+		},
+		md5:  "9A0A8659F005D6984697E2CA0A9CF3B7",
+		hmac: "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6",
+	},
+}
+
+const signFixtureKey = "192006250b4c09247ec02edce69f6a2d"
+
+func TestSignFixtures(t *testing.T) {
+	for _, f := range signFixtures {
+		if got := Sign(f.params, signFixtureKey); got != f.md5 {
+			t.Errorf("%s: MD5 sign = %s, want %s", f.name, got, f.md5)
+		}
+		if got := SignWithType(f.params, signFixtureKey, SignTypeHMACSHA256); got != f.hmac {
+			t.Errorf("%s: HMAC-SHA256 sign = %s, want %s", f.name, got, f.hmac)
+		}
+	}
+}
+
+func TestSignFixturesFromPayload(t *testing.T) {
+	payload := &OrderQueryPayload{AppId: "wxd930ea5d5a258f4f", MchId: "10000100", OutTradeNo: "A+B 1", NonceStr: "n0 n1"}
+	pm, err := toParams(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Sign(map[string]interface{}{
+		"appid":        "wxd930ea5d5a258f4f",
+		"mch_id":       "10000100",
+		"out_trade_no": "A+B 1",
+		"nonce_str":    "n0 n1",
+	}, signFixtureKey)
+	if got := Sign(pm, signFixtureKey); got != want {
+		t.Fatalf("payload sign = %s, want %s", got, want)
+	}
+}
+
+// 中文、+、空格及&的签名结果没有官方样例, 只校验待签名字符串: 参数值为原始UTF-8, 不做URL编码, 空值不参与
+func TestCanonicalStringRawValues(t *testing.T) {
+	params := map[string]interface{}{
+		"appid":     "wxd930ea5d5a258f4f",
+		"body":      "A+B 套餐 & 加料",
+		"attach":    "深圳分店",
+		"nonce_str": "n0 n1",
+		"detail":    "",
+		"total_fee": "1",
+	}
+	want := "appid=wxd930ea5d5a258f4f&attach=深圳分店&body=A+B 套餐 & 加料&nonce_str=n0 n1&total_fee=1"
+	if got := CanonicalString(params); got != want {
+		t.Fatalf("canonical string = %s, want %s", got, want)
+	}
+}
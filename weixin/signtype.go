@@ -0,0 +1,26 @@
+package weixin
+
+// endpointSignTypes 仅支持HMAC-SHA256签名的接口, 其它接口默认MD5
+var endpointSignTypes = map[string]string{
+	ProfitSharingAddReceiverURL:    SignTypeHMACSHA256,
+	ProfitSharingRemoveReceiverURL: SignTypeHMACSHA256,
+	ProfitSharingFinishURL:         SignTypeHMACSHA256,
+	ProfitSharingAmountQueryURL:    SignTypeHMACSHA256,
+	ProfitSharingReturnURL:         SignTypeHMACSHA256,
+}
+
+// DefaultSignTypeFor 接口默认签名类型, endpoint为接口URL
+func DefaultSignTypeFor(endpoint string) string {
+	if signType, ok := endpointSignTypes[endpoint]; ok {
+		return signType
+	}
+	return SignTypeMD5
+}
+
+// resolveSignType 调用方指定的签名类型优先, 未指定时取接口默认签名类型
+func resolveSignType(signType, endpoint string) string {
+	if signType != "" {
+		return signType
+	}
+	return DefaultSignTypeFor(endpoint)
+}
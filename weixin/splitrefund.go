@@ -0,0 +1,83 @@
+/*
+	已分账订单退款
+*/
+
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// ErrReturnProcessing 分账回退处理中, 尚未发起退款; 以相同的回退单号及退款单号重新调用RefundSplitOrder即可查询回退结果并继续
+var ErrReturnProcessing = errors.New("Profit sharing return is still processing")
+
+type SplitOrderInfo struct {
+	AppId         string        // 应用ID
+	MchId         string        // 商户号
+	TransactionId string        // 微信订单号
+	OutOrderNo    string        // 商户分账单号
+	OutRefundNo   string        // 商户退款单号
+	TotalFee      int           // 订单金额(分)
+	RefundFee     int           // 退款金额(分)
+	OpUserID      string        // 操作员账号
+	Returns       []SplitReturn // 各分账接收方需回退的金额
+}
+
+type SplitReturn struct {
+	ReturnAccount string // 回退方商户号
+	ReturnAmount  int    // 回退金额(分)
+	OutReturnNo   string // 商户回退单号, 重试时须保持不变
+}
+
+func RefundSplitOrder(order SplitOrderInfo, secretKey string, cert tls.Certificate) error {
+	return RefundSplitOrderWithContext(context.Background(), order, secretKey, cert)
+}
+
+// RefundSplitOrderWithContext 已分账订单退款: 先逐一向分账接收方发起分账回退, 全部回退成功后再向付款人退款.
+// 分账资金已转给接收方, 必须先回退再退款, 否则商户余额不足导致退款失败.
+// 任一回退失败即中止且不发起退款; 回退处理中时返回ErrReturnProcessing, 同样不发起退款.
+// 回退单号及退款单号保持不变时可安全重试, 已成功的回退重复调用返回原结果
+func RefundSplitOrderWithContext(ctx context.Context, order SplitOrderInfo, secretKey string, cert tls.Certificate) error {
+	if err := requireCert(cert); err != nil {
+		return err
+	}
+	for _, r := range order.Returns {
+		payload := &ProfitSharingReturnPayload{
+			MchId:             order.MchId,
+			AppId:             order.AppId,
+			NonceStr:          NonceStr(),
+			OutOrderNo:        order.OutOrderNo,
+			OutReturnNo:       r.OutReturnNo,
+			ReturnAccountType: ReceiverTypeMerchant,
+			ReturnAccount:     r.ReturnAccount,
+			ReturnAmount:      r.ReturnAmount,
+			Description:       "refund " + order.OutRefundNo,
+		}
+		response, err := ProfitSharingReturnWithContext(ctx, payload, secretKey, cert)
+		if err != nil {
+			return fmt.Errorf("Profit sharing return %s: %w", r.OutReturnNo, err)
+		}
+		switch response.Result {
+		case ReturnResultSuccess:
+		case ReturnResultProcessing:
+			return fmt.Errorf("Profit sharing return %s: %w", r.OutReturnNo, ErrReturnProcessing)
+		default:
+			return errors.New("Profit sharing return " + r.OutReturnNo + " failed: " + response.FailReason)
+		}
+	}
+	payload := &RefundPayload{
+		AppID:         order.AppId,
+		MchID:         order.MchId,
+		NonceStr:      NonceStr(),
+		TransactionID: order.TransactionId,
+		OutRefundNo:   order.OutRefundNo,
+		TotalFee:      order.TotalFee,
+		RefundFee:     order.RefundFee,
+		OpUserID:      order.OpUserID,
+	}
+	_, err := RefundWithClient(ctx, newCertClient(cert), payload, secretKey)
+	return err
+}
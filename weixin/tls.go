@@ -2,15 +2,151 @@ package weixin
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
 )
 
+// ErrCertificateRequired 需要商户API证书的接口未传入已加载的证书
+var ErrCertificateRequired = errors.New("Merchant API certificate is required")
+
+// requireCert 检查商户API证书已加载, 避免在TLS握手时才失败
+func requireCert(cert tls.Certificate) error {
+	if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+		return ErrCertificateRequired
+	}
+	return nil
+}
+
+// LoadCertFromPEM 从PEM格式数据加载商户API证书(apiclient_cert.pem/apiclient_key.pem)
+func LoadCertFromPEM(certPEM, keyPEM []byte) (tls.Certificate, error) {
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// LoadCertFromFile 从PEM格式文件加载商户API证书, 证书或私钥路径为空时返回ErrCertificateRequired
+func LoadCertFromFile(certPath, keyPath string) (tls.Certificate, error) {
+	if certPath == "" || keyPath == "" {
+		return tls.Certificate{}, ErrCertificateRequired
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// LoadCertFromP12 从商户平台下载的apiclient_cert.p12加载商户API证书及证书链,
+// 证书密码默认为商户号(mch_id)
+func LoadCertFromP12(p12 []byte, password string) (cert tls.Certificate, err error) {
+	blocks, err := pkcs12.ToPEM(p12, password)
+	if err != nil {
+		return
+	}
+	return certFromPEMBlocks(blocks)
+}
+
+// certFromPEMBlocks 由p12解出的PEM块构建证书, 以公钥与私钥匹配的证书为叶子证书,
+// 其余证书按原顺序作为证书链, p12中证书的顺序不固定
+func certFromPEMBlocks(blocks []*pem.Block) (cert tls.Certificate, err error) {
+	var certs []*pem.Block
+	var keyPEM []byte
+	for _, b := range blocks {
+		if b.Type == "CERTIFICATE" {
+			certs = append(certs, b)
+		} else {
+			keyPEM = append(keyPEM, pem.EncodeToMemory(b)...)
+		}
+	}
+	err = errors.New("No certificate matches the private key")
+	for i, leaf := range certs {
+		certPEM := pem.EncodeToMemory(leaf)
+		for j, b := range certs {
+			if j != i {
+				certPEM = append(certPEM, pem.EncodeToMemory(b)...)
+			}
+		}
+		if cert, err = LoadCertFromPEM(certPEM, keyPEM); err == nil {
+			return
+		}
+	}
+	return
+}
+
+// CertSerialNumber 证书序列号(大写十六进制)
+func CertSerialNumber(cert *x509.Certificate) string {
+	return fmt.Sprintf("%X", cert.SerialNumber)
+}
+
+// LeafCertificate 解析已加载商户API证书的叶子证书
+func LeafCertificate(cert tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("Missing certificate data")
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// TLSCertSerialNumber 已加载商户API证书的序列号
+func TLSCertSerialNumber(cert tls.Certificate) (serialNo string, err error) {
+	leaf, err := LeafCertificate(cert)
+	if err != nil {
+		return
+	}
+	serialNo = CertSerialNumber(leaf)
+	return
+}
+
+// CertExpiry 已加载商户API证书的到期时间
+func CertExpiry(cert tls.Certificate) (notAfter time.Time, err error) {
+	leaf, err := LeafCertificate(cert)
+	if err != nil {
+		return
+	}
+	notAfter = leaf.NotAfter
+	return
+}
+
+// CertExpiresWithin 商户API证书是否将在d内到期, 证书无法解析时返回true以便告警
+func CertExpiresWithin(cert tls.Certificate, d time.Duration) bool {
+	notAfter, err := CertExpiry(cert)
+	if err != nil {
+		return true
+	}
+	return time.Now().Add(d).After(notAfter)
+}
+
+// NewCertTLSConfig 使用商户API证书构建TLS配置, 最低版本TLS 1.2, 校验微信服务端证书.
+// 需要调整(如指定RootCAs)时修改返回值后经NewTLSClient传给XxxWithClient接口
+func NewCertTLSConfig(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+}
+
+// NewTLSClient 使用指定TLS配置的HTTP客户端, 超时为DefaultTimeout, 可在多个请求间复用.
+// 需要证书的接口的XxxWithClient版本接受此客户端, config中须含商户API证书
+func NewTLSClient(config *tls.Config) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config},
+		Timeout:   DefaultTimeout,
+	}
+}
+
+// newCertClient 使用商户API证书的HTTP客户端
+func newCertClient(cert tls.Certificate) *http.Client {
+	return NewTLSClient(NewCertTLSConfig(cert))
+}
+
 func NewTLSConfig(certPath string, keyPath string) (tlsConfig *tls.Config, err error) {
-	tlsConfig = new(tls.Config)
 	var cert tls.Certificate
-	cert, err = tls.LoadX509KeyPair(certPath, keyPath)
+	cert, err = LoadCertFromFile(certPath, keyPath)
 	if err != nil {
 		return
 	}
-	tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	tlsConfig = NewCertTLSConfig(cert)
 	return
 }
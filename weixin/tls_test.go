@@ -0,0 +1,153 @@
+package weixin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+func TestNewCertTLSConfigVerifiesServer(t *testing.T) {
+	config := NewCertTLSConfig(tls.Certificate{})
+	if config.InsecureSkipVerify {
+		t.Fatal("server certificate verification must be on")
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("MinVersion = %x, want TLS 1.2", config.MinVersion)
+	}
+}
+
+func TestDoRawWithClientUsesCallerClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<xml><return_code>SUCCESS</return_code></xml>"))
+	}))
+	defer server.Close()
+	if _, err := DoRawWithClient(context.Background(), &http.Client{}, server.URL, map[string]interface{}{"mch_id": "10000100"}, testSecretKey); err == nil {
+		t.Fatal("expected default client to reject the test server certificate")
+	}
+	body, err := DoRawWithClient(context.Background(), server.Client(), server.URL, map[string]interface{}{"mch_id": "10000100"}, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "<xml><return_code>SUCCESS</return_code></xml>" {
+		t.Fatalf("body = %s", body)
+	}
+}
+
+// testdata/apiclient_cert.p12: 商户证书(CN=10000100)及签发CA, 密码为商户号10000100
+const testP12Password = "10000100"
+
+func TestLoadCertFromP12(t *testing.T) {
+	p12, err := ioutil.ReadFile("testdata/apiclient_cert.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := LoadCertFromP12(p12, testP12Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.Certificate) != 2 {
+		t.Fatalf("chain length = %d, want 2", len(cert.Certificate))
+	}
+	leaf, err := LeafCertificate(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "10000100" {
+		t.Fatalf("leaf CN = %q, want merchant certificate", leaf.Subject.CommonName)
+	}
+	if _, err = LoadCertFromP12(p12, "wrong"); err == nil {
+		t.Fatal("expected error for wrong password")
+	}
+}
+
+func TestCertFromPEMBlocksPicksMatchingLeaf(t *testing.T) {
+	p12, err := ioutil.ReadFile("testdata/apiclient_cert.p12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := pkcs12.ToPEM(p12, testP12Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 证书倒序(CA证书在前), 私钥在后
+	var reordered []*pem.Block
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].Type == "CERTIFICATE" {
+			reordered = append(reordered, blocks[i])
+		}
+	}
+	for _, b := range blocks {
+		if b.Type != "CERTIFICATE" {
+			reordered = append(reordered, b)
+		}
+	}
+	first, err := x509.ParseCertificate(reordered[0].Bytes)
+	if err != nil || first.Subject.CommonName == "10000100" {
+		t.Fatalf("fixture must put the CA first: %v", err)
+	}
+	cert, err := certFromPEMBlocks(reordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := LeafCertificate(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Subject.CommonName != "10000100" {
+		t.Fatalf("leaf CN = %q, want merchant certificate", leaf.Subject.CommonName)
+	}
+}
+
+func TestTLSCertSerialNumber(t *testing.T) {
+	cert, err := LoadCertFromFile("testdata/apiclient_cert.pem", "testdata/apiclient_key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialNo, err := TLSCertSerialNumber(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "5A3B1C9DE2F407A6B8C9D0E1F2A3B4C5D6E7F809"; serialNo != want {
+		t.Fatalf("serial = %s, want %s", serialNo, want)
+	}
+	notAfter, err := CertExpiry(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2126, 9, 22, 0, 41, 12, 0, time.UTC); !notAfter.Equal(want) {
+		t.Fatalf("NotAfter = %v, want %v", notAfter, want)
+	}
+	if CertExpiresWithin(cert, 24*time.Hour) {
+		t.Fatal("fixture certificate should not be expiring")
+	}
+}
+
+func TestDoRawWithClientSendsDefaultSignType(t *testing.T) {
+	var requests []map[string]string
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code></xml>", &requests)
+	params := map[string]interface{}{"mch_id": "10000100", "appid": "wx123", "nonce_str": "n1", "transaction_id": "4200000001"}
+	if _, err := DoRawWithClient(context.Background(), redirectClient(server), ProfitSharingAmountQueryURL, params, testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+	sent := requests[0]
+	if sent["sign_type"] != SignTypeHMACSHA256 {
+		t.Fatalf("sign_type = %q, want %s", sent["sign_type"], SignTypeHMACSHA256)
+	}
+	want := SignWithType(map[string]interface{}{
+		"mch_id": "10000100", "appid": "wx123", "nonce_str": "n1", "transaction_id": "4200000001", "sign_type": SignTypeHMACSHA256,
+	}, testSecretKey, SignTypeHMACSHA256)
+	if sent["sign"] != want {
+		t.Fatalf("sign = %s, want %s", sent["sign"], want)
+	}
+	if _, ok := params["sign_type"]; ok {
+		t.Fatal("DoRawWithClient must not modify params")
+	}
+}
@@ -6,102 +6,259 @@
 package weixin
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"encoding/xml"
 	"errors"
-	"fmt"
-	"io/ioutil"
+	"math"
 	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
 	UnifiedOrderURL string = "https://api.mch.weixin.qq.com/pay/unifiedorder"
 )
 
+// CheckNotifyURL PreSignCheck中是否使用ValidateNotifyURL校验notify_url
+var CheckNotifyURL = false
+
+// MinOrderExpire 订单失效时间距生成时间的最小间隔
+const MinOrderExpire = 5 * time.Minute
+
+// PrepayIdTTL prepay_id有效期
+const PrepayIdTTL = 2 * time.Hour
+
+// MaxTotalFee 订单金额上限(分), 微信total_fee为32位Int
+const MaxTotalFee = math.MaxInt32
+
 const (
 	TradeTypeAPP    string = "APP"
 	TradeTypeJSAPI  string = "JSAPI"
 	TradeTypeNative string = "NATIVE"
+	TradeTypeMWEB   string = "MWEB"
 )
 
 type UnifiedOrderPayload struct {
-	AppId          string `json:"appid,omitempty" xml:"appid,omitempty"`                       // R. 应用ID
-	MchId          string `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                     // R. 商户号
-	DeviceInfo     string `json:"device_info,omitempty" xml:"device_info,omitempty"`           // O. 设备号
-	NonceStr       string `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`               // R. 随机字符串
-	Sign           string `json:"sign,omitempty" xml:"sign,omitempty"`                         // R. 签名
-	SignType       string `json:"sign_type,omitempty" xml:"sign_type,omitempty"`               // R. 签名类型,默认MD5
-	Body           string `json:"body,omitempty" xml:"body,omitempty"`                         // R. 交易描述
-	Detail         string `json:"detail,omitempty" xml:"detail,omitempty"`                     // O. 交易商品详情
-	Attach         string `json:"attach,omitempty" xml:"attach,omitempty"`                     // O. 附加数据
-	OutTradeNo     string `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`         // R. 商户交易号
-	FeeType        string `json:"fee_type,omitempty" xml:"fee_type,omitempty"`                 // O. 货币类型
-	TotalFee       int    `json:"total_fee,omitempty" xml:"total_fee,omitempty"`               // R. 订单总金额(分)
-	SPBillCreateIp string `json:"spbill_create_ip,omitempty" xml:"spbill_create_ip,omitempty"` // R. 终端IP
-	TimeStart      string `json:"time_start,omitempty" xml:"time_start,omitempty"`             // O. 订单生成时间(yyyyMMddHHmmss)
-	TimeExpire     string `json:"time_expire,omitempty" xml:"time_expire,omitempty"`           // O. 订单失效时间(yyyyMMddHHmmss)
-	GoodsTag       string `json:"goods_tag,omitempty" xml:"goods_tag,omitempty"`               // O. 商品标记
-	NotifyURL      string `json:"notify_url,omitempty" xml:"notify_url,omitempty"`             // R. 交易回调URL
-	TradeType      string `json:"trade_type,omitempty" xml:"trade_type,omitempty"`             // R. 交易类型(APP/NATIVE/JSAPI)
-	LimitPay       string `json:"limit_pay,omitempty" xml:"limit_pay,omitempty"`               // O. 指定支付方式(no_credit: 不能使用信用卡支付)
-	OpenID         string `json:"open_id,omitempty" xml:"open_id,omitempty"`                   // O. 用户标识(trade_type为JSAPI时，此参数必传)
-	ProductID      string `json:"product_id,omitempty" xml:"product_id,omitempty"`             // O. 商品ID(trade_type为Native时，此参数比传)
+	XMLName        xml.Name `xml:"xml" json:"-"`
+	AppId          string   `json:"appid,omitempty" xml:"appid,omitempty"`                       // R. 应用ID
+	MchId          string   `json:"mch_id,omitempty" xml:"mch_id,omitempty"`                     // R. 商户号
+	DeviceInfo     string   `json:"device_info,omitempty" xml:"device_info,omitempty"`           // O. 设备号
+	NonceStr       string   `json:"nonce_str,omitempty" xml:"nonce_str,omitempty"`               // R. 随机字符串
+	Sign           string   `json:"sign,omitempty" xml:"sign,omitempty"`                         // R. 签名
+	SignType       string   `json:"sign_type,omitempty" xml:"sign_type,omitempty"`               // R. 签名类型,默认MD5
+	Body           string   `json:"body,omitempty" xml:"body,omitempty"`                         // R. 交易描述
+	Detail         string   `json:"detail,omitempty" xml:"detail,omitempty"`                     // O. 交易商品详情
+	Attach         string   `json:"attach,omitempty" xml:"attach,omitempty"`                     // O. 附加数据
+	OutTradeNo     string   `json:"out_trade_no,omitempty" xml:"out_trade_no,omitempty"`         // R. 商户交易号
+	FeeType        string   `json:"fee_type,omitempty" xml:"fee_type,omitempty"`                 // O. 货币类型
+	TotalFee       int      `json:"total_fee,omitempty" xml:"total_fee,omitempty"`               // R. 订单总金额(分), 零值省略, 由PreSignCheck保证必传
+	SPBillCreateIp string   `json:"spbill_create_ip,omitempty" xml:"spbill_create_ip,omitempty"` // R. 终端IP
+	TimeStart      string   `json:"time_start,omitempty" xml:"time_start,omitempty"`             // O. 订单生成时间(yyyyMMddHHmmss)
+	TimeExpire     string   `json:"time_expire,omitempty" xml:"time_expire,omitempty"`           // O. 订单失效时间(yyyyMMddHHmmss)
+	GoodsTag       string   `json:"goods_tag,omitempty" xml:"goods_tag,omitempty"`               // O. 商品标记
+	NotifyURL      string   `json:"notify_url,omitempty" xml:"notify_url,omitempty"`             // R. 交易回调URL
+	TradeType      string   `json:"trade_type,omitempty" xml:"trade_type,omitempty"`             // R. 交易类型(APP/NATIVE/JSAPI/MWEB)
+	LimitPay       string   `json:"limit_pay,omitempty" xml:"limit_pay,omitempty"`               // O. 指定支付方式(no_credit: 不能使用信用卡支付)
+	OpenID         string   `json:"openid,omitempty" xml:"openid,omitempty"`                     // O. 用户标识(trade_type为JSAPI时，此参数必传)
+	ProductID      string   `json:"product_id,omitempty" xml:"product_id,omitempty"`             // O. 商品ID(NATIVE模式二统一下单可不传, 模式一bizpayurl必传且参与签名)
+	Receipt        string   `json:"receipt,omitempty" xml:"receipt,omitempty"`                   // O. 电子发票入口开放标识(Y)
+	SceneInfo      string   `json:"scene_info,omitempty" xml:"scene_info,omitempty"`             // O. 场景信息JSON(trade_type为MWEB时必传)
+
+	ExtraParams map[string]string `json:"-" xml:"-"` // O. 结构体未覆盖的新增字段, 与上述字段一同参与签名并写入XML, 不得与上述字段重名
 }
 
-func (this *UnifiedOrderPayload) IsJSAPI() bool {
-	return this.TradeType == TradeTypeJSAPI
+func (this *UnifiedOrderPayload) signFields() (sign, signType *string) {
+	return &this.Sign, &this.SignType
 }
 
-func (this *UnifiedOrderPayload) IsNative() bool {
-	return this.TradeType == TradeTypeNative
+type unifiedOrderPayloadXML UnifiedOrderPayload
+
+type extraParam struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
 }
 
-func (this *UnifiedOrderPayload) PreSignCheck() (err error) {
-	if this.AppId == "" {
-		err = errors.New("Missing required parameters: appid")
-		return
+// MarshalXML 在结构体字段之后按参数名排序追加ExtraParams中的非空字段,
+// 保证输出顺序与map遍历顺序无关. 根元素固定为<xml>
+func (this UnifiedOrderPayload) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "xml"}
+	keys := []string{}
+	for k, v := range this.ExtraParams {
+		if v != "" {
+			keys = append(keys, k)
+		}
 	}
-	if this.MchId == "" {
-		err = errors.New("Missing required parameters: mch_id")
-		return
+	sort.Strings(keys)
+	extras := make([]extraParam, 0, len(keys))
+	for _, k := range keys {
+		extras = append(extras, extraParam{XMLName: xml.Name{Local: k}, Value: this.ExtraParams[k]})
 	}
-	if this.Body == "" {
-		err = errors.New("Missing required parameters: body")
-		return
+	alias := unifiedOrderPayloadXML(this)
+	return e.EncodeElement(struct {
+		*unifiedOrderPayloadXML
+		Extras []extraParam `xml:",any"`
+	}{&alias, extras}, start)
+}
+
+// unifiedOrderFields 结构体字段对应的参数名, ExtraParams不得与之重复
+var unifiedOrderFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(UnifiedOrderPayload{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
 	}
-	if this.NonceStr == "" {
-		err = errors.New("Missing required parameters: nonce_str")
+	return fields
+}()
+
+// signParams 签名参数, 含ExtraParams
+func (this *UnifiedOrderPayload) signParams() (pm map[string]interface{}, err error) {
+	pm, err = toParams(this)
+	if err != nil {
 		return
 	}
-	if this.OutTradeNo == "" {
-		err = errors.New("Missing required parameters: out_trade_no")
-		return
+	for k, v := range this.ExtraParams {
+		pm[k] = v
 	}
-	if this.TotalFee == 0 {
-		err = errors.New("Missing required parameters: total_fee")
-		return
+	return
+}
+
+func newUnifiedOrder(tradeType, body, outTradeNo string, totalFee int, notifyURL string) *UnifiedOrderPayload {
+	return &UnifiedOrderPayload{
+		NonceStr:   NonceStr(),
+		Body:       body,
+		OutTradeNo: outTradeNo,
+		TotalFee:   totalFee,
+		NotifyURL:  notifyURL,
+		TradeType:  tradeType,
 	}
-	if this.SPBillCreateIp == "" {
-		err = errors.New("Missing required parameters: spbill_create_ip")
-		return
+}
+
+// NewJSAPIOrder 公众号/小程序支付订单, 仍需设置AppId、MchId、SPBillCreateIp
+func NewJSAPIOrder(body, outTradeNo string, totalFee int, openid, notifyURL string) *UnifiedOrderPayload {
+	payload := newUnifiedOrder(TradeTypeJSAPI, body, outTradeNo, totalFee, notifyURL)
+	payload.OpenID = openid
+	return payload
+}
+
+// NewNativeOrder 扫码支付(模式二)订单, productID可为空, 仍需设置AppId、MchId、SPBillCreateIp
+func NewNativeOrder(body, outTradeNo string, totalFee int, productID, notifyURL string) *UnifiedOrderPayload {
+	payload := newUnifiedOrder(TradeTypeNative, body, outTradeNo, totalFee, notifyURL)
+	payload.ProductID = productID
+	return payload
+}
+
+// NewAppOrder APP支付订单, 仍需设置AppId、MchId、SPBillCreateIp
+func NewAppOrder(body, outTradeNo string, totalFee int, notifyURL string) *UnifiedOrderPayload {
+	return newUnifiedOrder(TradeTypeAPP, body, outTradeNo, totalFee, notifyURL)
+}
+
+// ValidateNotifyURL 校验notify_url: 须为https地址且不能携带参数
+func ValidateNotifyURL(notifyURL string) error {
+	u, err := url.Parse(notifyURL)
+	if err != nil {
+		return err
 	}
-	if this.NotifyURL == "" {
-		err = errors.New("Missing required parameters: notify_url")
-		return
+	if u.Scheme != "https" {
+		return errors.New("Invalid notify_url: scheme must be https")
 	}
-	if this.TradeType == "" {
-		err = errors.New("Missing required parameters: trade_type")
-		return
+	if u.Host == "" {
+		return errors.New("Invalid notify_url: missing host")
 	}
-	if this.IsJSAPI() && this.OpenID == "" {
-		err = errors.New("Missing required paramters for JSAPI payment: openid")
-		return
+	if u.RawQuery != "" || strings.HasSuffix(notifyURL, "?") {
+		return errors.New("Invalid notify_url: query parameters are not allowed")
 	}
-	if this.IsNative() && this.ProductID == "" {
-		err = errors.New("Missing required paramters for NATIVE payment: product_id")
+	return nil
+}
+
+// SetTimeWindow 按北京时间同时设置订单生成时间及失效时间(start+d), d不得小于MinOrderExpire
+func (this *UnifiedOrderPayload) SetTimeWindow(start time.Time, d time.Duration) error {
+	if d < MinOrderExpire {
+		return errors.New("Invalid time_expire: must be at least 5 minutes after time_start")
+	}
+	this.TimeStart = start.In(ChinaLocation).Format(ChinaTimeLayout)
+	this.TimeExpire = start.Add(d).In(ChinaLocation).Format(ChinaTimeLayout)
+	return nil
+}
+
+// SetClientIP 设置终端IP, remoteAddr可为http.Request.RemoteAddr, 可链式调用
+func (this *UnifiedOrderPayload) SetClientIP(remoteAddr string) *UnifiedOrderPayload {
+	this.SPBillCreateIp = NormalizeClientIP(remoteAddr)
+	return this
+}
+
+// SetReceipt 设置是否在支付成功页展示电子发票入口, 可链式调用:
+// NewJSAPIOrder(...).SetReceipt(true)
+func (this *UnifiedOrderPayload) SetReceipt(receipt bool) *UnifiedOrderPayload {
+	if receipt {
+		this.Receipt = "Y"
+	} else {
+		this.Receipt = ""
+	}
+	return this
+}
+
+func (this *UnifiedOrderPayload) IsJSAPI() bool {
+	return this.TradeType == TradeTypeJSAPI
+}
+
+func (this *UnifiedOrderPayload) IsNative() bool {
+	return this.TradeType == TradeTypeNative
+}
+
+// PreSignCheck 检查必传参数, 不修改payload. spbill_create_ip须为不带端口的IP地址,
+// 可经SetClientIP设置; 下单及MarshalPayload在检查前自动经NormalizeClientIP转换
+func (this *UnifiedOrderPayload) PreSignCheck() (err error) {
+	v := &ValidationError{}
+	v.require("appid", this.AppId != "")
+	v.require("mch_id", this.MchId != "")
+	v.require("body", this.Body != "")
+	v.require("nonce_str", this.NonceStr != "")
+	v.require("out_trade_no", this.OutTradeNo != "")
+	v.require("total_fee", this.TotalFee != 0)
+	v.check("total_fee", this.TotalFee >= 0 && this.TotalFee <= MaxTotalFee)
+	v.require("spbill_create_ip", this.SPBillCreateIp != "")
+	v.check("spbill_create_ip", this.SPBillCreateIp == "" || ValidClientIP(this.SPBillCreateIp))
+	v.require("notify_url", this.NotifyURL != "")
+	v.check("notify_url", !CheckNotifyURL || this.NotifyURL == "" || ValidateNotifyURL(this.NotifyURL) == nil)
+	v.require("trade_type", this.TradeType != "")
+	v.check("goods_tag", this.GoodsTag == "" || ValidGoodsTag(this.GoodsTag))
+	for k := range this.ExtraParams {
+		v.check("extra_params."+k, k != "" && !unifiedOrderFields[k])
+	}
+	this.validateTradeType(v)
+	return v.err()
+}
+
+type tradeTypeRule struct {
+	field   string
+	present func(payload *UnifiedOrderPayload) bool
+}
+
+// tradeTypeRules 各交易类型额外必传字段
+var tradeTypeRules = map[string][]tradeTypeRule{
+	TradeTypeJSAPI: {
+		{"openid", func(payload *UnifiedOrderPayload) bool { return payload.OpenID != "" }},
+	},
+	TradeTypeNative: {},
+	TradeTypeAPP:    {},
+	TradeTypeMWEB: {
+		{"scene_info", func(payload *UnifiedOrderPayload) bool { return payload.SceneInfo != "" }},
+	},
+}
+
+// validateTradeType trade_type须为TradeType*常量之一(区分大小写), 并检查其额外必传字段
+func (this *UnifiedOrderPayload) validateTradeType(v *ValidationError) {
+	rules, ok := tradeTypeRules[this.TradeType]
+	v.check("trade_type", this.TradeType == "" || ok)
+	for _, rule := range rules {
+		v.require(rule.field, rule.present(this))
 	}
-	return
 }
 
 type UnifiedOrderResp struct {
@@ -117,14 +274,54 @@ type UnifiedOrderResp struct {
 	PrepayId   string `xml:"prepay_id"`
 	TradeType  string `xml:"trade_type"`
 	CodeURL    string `xml:"code_url"`
+	MwebURL    string `xml:"mweb_url"`
 }
 
 func (this *UnifiedOrderResp) IsSuccess() bool {
 	return this.ResultCode == "SUCCESS" && this.ReturnCode == "SUCCESS"
 }
 
+// PrepayID 下单成功时返回prepay_id, 失败或prepay_id为空时返回错误
+func (this *UnifiedOrderResp) PrepayID() (string, error) {
+	if !this.IsSuccess() {
+		return "", errors.New("Unified order failed: " + this.ErrCodeDes)
+	}
+	if this.PrepayId == "" {
+		return "", errors.New("Missing prepay_id in unified order response")
+	}
+	return this.PrepayId, nil
+}
+
+// checkIdentifier 校验下单成功的响应中含有交易类型对应的标识, 避免以空值生成调起支付参数
+func (this *UnifiedOrderResp) checkIdentifier(tradeType string) error {
+	switch tradeType {
+	case TradeTypeNative:
+		if this.CodeURL == "" {
+			return errors.New("Missing code_url in unified order response")
+		}
+	case TradeTypeMWEB:
+		if this.MwebURL == "" {
+			return errors.New("Missing mweb_url in unified order response")
+		}
+	default:
+		if this.PrepayId == "" {
+			return errors.New("Missing prepay_id in unified order response")
+		}
+	}
+	return nil
+}
+
+// PrepayExpiry prepay_id失效时间, createdAt为统一下单成功的时间.
+// 缓存调起支付参数时应在此之前重新下单, 否则会提示订单已失效
+func PrepayExpiry(createdAt time.Time) time.Time {
+	return createdAt.Add(PrepayIdTTL)
+}
+
+// JSAPI 公众号/小程序调起支付参数, 有效期同prepay_id(见PrepayExpiry).
+// timeStamp为参数生成时间, 不是prepay_id的生成时间, 不能用于推算失效时间.
+// 交易类型不符或prepay_id为空时返回nil, 需要错误原因时先调用PrepayID
 func (this *UnifiedOrderResp) JSAPI(secretKey string) map[string]interface{} {
-	if this.TradeType != TradeTypeJSAPI {
+	if this.TradeType != TradeTypeJSAPI || this.PrepayId == "" {
 		return nil
 	}
 	results := map[string]interface{}{
@@ -139,8 +336,37 @@ func (this *UnifiedOrderResp) JSAPI(secretKey string) map[string]interface{} {
 	return results
 }
 
+// JSAPIPayParams wx.requestPayment / WeixinJSBridge调起支付参数
+type JSAPIPayParams struct {
+	AppID     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// JSAPITyped 同JSAPI, 返回类型化的调起支付参数
+func (this *UnifiedOrderResp) JSAPITyped(secretKey string) *JSAPIPayParams {
+	results := this.JSAPI(secretKey)
+	if results == nil {
+		return nil
+	}
+	return &JSAPIPayParams{
+		AppID:     results["appId"].(string),
+		TimeStamp: results["timeStamp"].(string),
+		NonceStr:  results["nonceStr"].(string),
+		Package:   results["package"].(string),
+		SignType:  results["signType"].(string),
+		PaySign:   results["paySign"].(string),
+	}
+}
+
+// APP APP调起支付参数, 有效期同prepay_id(见PrepayExpiry).
+// timestamp为参数生成时间, 不是prepay_id的生成时间, 不能用于推算失效时间.
+// 交易类型不符或prepay_id为空时返回nil, 需要错误原因时先调用PrepayID
 func (this *UnifiedOrderResp) APP(secretKey string) map[string]interface{} {
-	if this.TradeType != TradeTypeAPP {
+	if this.TradeType != TradeTypeAPP || this.PrepayId == "" {
 		return nil
 	}
 	results := map[string]interface{}{
@@ -156,6 +382,34 @@ func (this *UnifiedOrderResp) APP(secretKey string) map[string]interface{} {
 	return results
 }
 
+// APPPayParams Android/iOS SDK调起支付参数(PayReq)
+type APPPayParams struct {
+	AppID     string `json:"appid"`
+	PartnerID string `json:"partnerid"`
+	PrepayID  string `json:"prepayid"`
+	Package   string `json:"package"`
+	NonceStr  string `json:"noncestr"`
+	Timestamp string `json:"timestamp"`
+	Sign      string `json:"sign"`
+}
+
+// APPTyped 同APP, 返回类型化的调起支付参数
+func (this *UnifiedOrderResp) APPTyped(secretKey string) *APPPayParams {
+	results := this.APP(secretKey)
+	if results == nil {
+		return nil
+	}
+	return &APPPayParams{
+		AppID:     results["appid"].(string),
+		PartnerID: results["partnerid"].(string),
+		PrepayID:  results["prepayid"].(string),
+		Package:   results["package"].(string),
+		NonceStr:  results["noncestr"].(string),
+		Timestamp: results["timestamp"].(string),
+		Sign:      results["sign"].(string),
+	}
+}
+
 func (this *UnifiedOrderResp) Native() string {
 	if this.TradeType != TradeTypeNative {
 		return ""
@@ -164,46 +418,92 @@ func (this *UnifiedOrderResp) Native() string {
 }
 
 func UnifiedOrder(payload *UnifiedOrderPayload, secretKey string) (response UnifiedOrderResp, err error) {
-	if preSignErr := payload.PreSignCheck(); preSignErr != nil {
-		err = preSignErr
+	return UnifiedOrderWithContext(context.Background(), payload, secretKey)
+}
+
+// JSAPIOrder JSAPI下单结果, prepay_id用于记录及排查, PayParams交给前端调起支付
+type JSAPIOrder struct {
+	PrepayID  string
+	PayParams *JSAPIPayParams
+}
+
+func UnifiedOrderJSAPI(payload *UnifiedOrderPayload, secretKey string) (order *JSAPIOrder, err error) {
+	return UnifiedOrderJSAPIWithContext(context.Background(), payload, secretKey)
+}
+
+// UnifiedOrderJSAPIWithContext JSAPI统一下单并生成调起支付参数, payload的trade_type须为JSAPI.
+// 响应中prepay_id为空时返回错误, 不生成调起支付参数
+func UnifiedOrderJSAPIWithContext(ctx context.Context, payload *UnifiedOrderPayload, secretKey string) (order *JSAPIOrder, err error) {
+	return UnifiedOrderJSAPIWithClient(ctx, &http.Client{Timeout: DefaultTimeout}, payload, secretKey)
+}
+
+// UnifiedOrderJSAPIWithClient 同UnifiedOrderJSAPIWithContext, 使用调用方提供的HTTP客户端
+func UnifiedOrderJSAPIWithClient(ctx context.Context, c *http.Client, payload *UnifiedOrderPayload, secretKey string) (order *JSAPIOrder, err error) {
+	if !payload.IsJSAPI() {
+		err = errors.New("Invalid trade_type: must be JSAPI")
 		return
 	}
-	bs, _ := json.Marshal(payload)
-	pm := make(map[string]interface{})
-	if err1 := json.Unmarshal(bs, &pm); err1 != nil {
-		err = err1
+	response, err := UnifiedOrderWithClient(ctx, c, payload, secretKey)
+	if err != nil {
 		return
 	}
-	sign := Sign(pm, secretKey)
-	payload.Sign = sign
-	XML, _ := xml.Marshal(payload)
-	req, err2 := http.NewRequest(
-		"POST",
-		UnifiedOrderURL,
-		bytes.NewReader(XML))
-	if err2 != nil {
-		err = err2
+	prepayId, err := response.PrepayID()
+	if err != nil {
 		return
 	}
-	req.Header.Set("Accept", "application/xml")
-	req.Header.Set("Content-Type", "application/xml;charset=utf-8")
-	c := http.Client{}
-	resp, err3 := c.Do(req)
-	if err3 != nil {
-		err = err3
+	order = &JSAPIOrder{
+		PrepayID:  prepayId,
+		PayParams: response.JSAPITyped(secretKey),
+	}
+	return
+}
+
+// UnifiedOrderWithNonce 使用指定的随机字符串下单, 用于复现请求签名
+func UnifiedOrderWithNonce(payload *UnifiedOrderPayload, secretKey string, nonce string) (response UnifiedOrderResp, err error) {
+	return UnifiedOrderWithNonceWithContext(context.Background(), payload, secretKey, nonce)
+}
+
+func UnifiedOrderWithNonceWithContext(ctx context.Context, payload *UnifiedOrderPayload, secretKey string, nonce string) (response UnifiedOrderResp, err error) {
+	payload.NonceStr = nonce
+	return UnifiedOrderWithContext(ctx, payload, secretKey)
+}
+
+// MarshalPayload 检查参数并签名, 返回实际发送的XML请求体.
+// nonce_str为空时自动生成, 调用方已设置的nonce_str保持不变;
+// 按sign_type签名, 使用HMAC-SHA256时sign_type同时参与签名并写入XML
+func MarshalPayload(payload *UnifiedOrderPayload, secretKey string) (XML []byte, err error) {
+	if err = payload.prepare(secretKey); err != nil {
 		return
 	}
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	fmt.Println(string(body))
-	response = UnifiedOrderResp{}
-	if err4 := xml.Unmarshal(body, &response); err4 != nil {
-		err = err4
+	return signPayload(UnifiedOrderURL, payload, secretKey)
+}
+
+// prepare 补全nonce_str、转换spbill_create_ip并检查参数及密钥
+func (this *UnifiedOrderPayload) prepare(secretKey string) error {
+	if this.NonceStr == "" {
+		this.NonceStr = NonceStr()
+	}
+	this.SPBillCreateIp = NormalizeClientIP(this.SPBillCreateIp)
+	if err := this.PreSignCheck(); err != nil {
+		return err
+	}
+	return CheckSecretKey(secretKey)
+}
+
+func UnifiedOrderWithContext(ctx context.Context, payload *UnifiedOrderPayload, secretKey string) (response UnifiedOrderResp, err error) {
+	return UnifiedOrderWithClient(ctx, &http.Client{Timeout: DefaultTimeout}, payload, secretKey)
+}
+
+// UnifiedOrderWithClient 使用调用方提供的HTTP客户端统一下单
+func UnifiedOrderWithClient(ctx context.Context, c *http.Client, payload *UnifiedOrderPayload, secretKey string) (response UnifiedOrderResp, err error) {
+	if err = payload.prepare(secretKey); err != nil {
 		return
 	}
-	if !response.IsSuccess() {
-		err = errors.New(response.ErrCodeDes)
+	_, err1 := doXMLRequest(ctx, c, UnifiedOrderURL, payload, secretKey, &response)
+	if err1 != nil {
+		err = err1
 		return
 	}
+	err = response.checkIdentifier(payload.TradeType)
 	return
 }
@@ -0,0 +1,226 @@
+package weixin
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testOrder(tradeType string) *UnifiedOrderPayload {
+	payload := newUnifiedOrder(tradeType, "test", "T1", 1, "https://example.com/notify")
+	payload.AppId = "wx123"
+	payload.MchId = "10000100"
+	payload.SPBillCreateIp = "1.2.3.4"
+	payload.NonceStr = "5K8264ILTKCH16CQ2502SI8ZNMTM67VS"
+	return payload
+}
+
+func TestNativeOrderWithoutProductID(t *testing.T) {
+	payload := NewNativeOrder("test", "T1", 1, "", "https://example.com/notify")
+	payload.AppId, payload.MchId, payload.SPBillCreateIp = "wx123", "10000100", "1.2.3.4"
+	if err := payload.PreSignCheck(); err != nil {
+		t.Fatalf("mode 2 NATIVE order without product_id rejected: %v", err)
+	}
+}
+
+// goldenNativeXML testOrder(NATIVE)加product_id、attach后以testSecretKey签名的请求报文
+const goldenNativeXML = "<xml><appid>wx123</appid><mch_id>10000100</mch_id>" +
+	"<nonce_str>5K8264ILTKCH16CQ2502SI8ZNMTM67VS</nonce_str><sign>D98FC71B4869C63593EB6E59B05295FE</sign>" +
+	"<body>test</body><attach>A+B &amp; &lt;C&gt;</attach><out_trade_no>T1</out_trade_no><total_fee>1</total_fee>" +
+	"<spbill_create_ip>1.2.3.4</spbill_create_ip><notify_url>https://example.com/notify</notify_url>" +
+	"<trade_type>NATIVE</trade_type><product_id>P1</product_id></xml>"
+
+func goldenNativeOrder() *UnifiedOrderPayload {
+	payload := testOrder(TradeTypeNative)
+	payload.ProductID = "P1"
+	payload.Attach = "A+B & <C>"
+	return payload
+}
+
+func TestMarshalPayloadGolden(t *testing.T) {
+	XML, err := MarshalPayload(goldenNativeOrder(), testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(XML) != goldenNativeXML {
+		t.Fatalf("XML =\n%s\nwant\n%s", XML, goldenNativeXML)
+	}
+}
+
+func TestMarshalPayloadMatchesWire(t *testing.T) {
+	var sent []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><code_url>weixin://wxpay/s/1</code_url></xml>"))
+	}))
+	defer server.Close()
+	payload := goldenNativeOrder()
+	response := UnifiedOrderResp{}
+	if _, err := doXMLRequest(context.Background(), server.Client(), server.URL, payload, testSecretKey, &response); err != nil {
+		t.Fatal(err)
+	}
+	if string(sent) != goldenNativeXML {
+		t.Fatalf("sent =\n%s\nwant\n%s", sent, goldenNativeXML)
+	}
+}
+
+func TestExtraParamsSignedAndSent(t *testing.T) {
+	payload := testOrder(TradeTypeNative)
+	payload.ExtraParams = map[string]string{"fund_account": "X", "a_new": "1"}
+	XML, err := MarshalPayload(payload, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 签名按全部参数(含ExtraParams)排序计算, 与去掉ExtraParams时不同
+	if payload.Sign != "ED1C6F7F31ADA35D10D9F8580EFD104C" {
+		t.Fatalf("sign = %s", payload.Sign)
+	}
+	if !strings.HasSuffix(string(XML), "<trade_type>NATIVE</trade_type><a_new>1</a_new><fund_account>X</fund_account></xml>") {
+		t.Fatalf("extra params missing or unsorted in %s", XML)
+	}
+}
+
+func TestExtraParamsCannotShadowFields(t *testing.T) {
+	for _, key := range []string{"total_fee", "sign", "notify_url", ""} {
+		payload := testOrder(TradeTypeNative)
+		payload.ExtraParams = map[string]string{key: "1"}
+		if err := payload.PreSignCheck(); err == nil {
+			t.Errorf("ExtraParams[%q] accepted", key)
+		}
+	}
+}
+
+func TestCheckIdentifier(t *testing.T) {
+	cases := []struct {
+		tradeType string
+		missing   string
+		present   UnifiedOrderResp
+	}{
+		{TradeTypeJSAPI, "prepay_id", UnifiedOrderResp{PrepayId: "wx201410272009395522657a690389285100"}},
+		{TradeTypeAPP, "prepay_id", UnifiedOrderResp{PrepayId: "wx201410272009395522657a690389285100"}},
+		{TradeTypeNative, "code_url", UnifiedOrderResp{CodeURL: "weixin://wxpay/bizpayurl/up?pr=NwY5Mz9"}},
+		{TradeTypeMWEB, "mweb_url", UnifiedOrderResp{MwebURL: "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx1"}},
+	}
+	for _, c := range cases {
+		if err := c.present.checkIdentifier(c.tradeType); err != nil {
+			t.Errorf("%s: %v", c.tradeType, err)
+		}
+		empty := UnifiedOrderResp{ReturnCode: "SUCCESS", ResultCode: "SUCCESS"}
+		err := empty.checkIdentifier(c.tradeType)
+		if err == nil || !strings.Contains(err.Error(), c.missing) {
+			t.Errorf("%s: err = %v, want missing %s", c.tradeType, err, c.missing)
+		}
+	}
+}
+
+func TestUnifiedOrderRejectsEmptyIdentifier(t *testing.T) {
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><trade_type>JSAPI</trade_type><prepay_id></prepay_id></xml>", nil)
+	payload := testOrder(TradeTypeJSAPI)
+	payload.OpenID = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+	response, err := UnifiedOrderWithClient(context.Background(), redirectClient(server), payload, testSecretKey)
+	if err == nil || !strings.Contains(err.Error(), "prepay_id") {
+		t.Fatalf("err = %v, want missing prepay_id error", err)
+	}
+	if response.JSAPI(testSecretKey) != nil {
+		t.Fatal("JSAPI params built from empty prepay_id")
+	}
+}
+
+func TestTradeTypeValidation(t *testing.T) {
+	for _, tradeType := range []string{"App", "app", "jsapi", "Native", "mweb", "WAP", "MICROPAY", " APP"} {
+		payload := testOrder(tradeType)
+		err := payload.PreSignCheck()
+		if err == nil || !strings.Contains(err.Error(), "trade_type") {
+			t.Errorf("trade_type %q: err = %v, want trade_type error", tradeType, err)
+		}
+	}
+	for _, payload := range []*UnifiedOrderPayload{
+		testOrder(TradeTypeAPP),
+		testOrder(TradeTypeNative),
+		testOrder(TradeTypeJSAPI).SetClientIP("1.2.3.4"),
+		testOrder(TradeTypeMWEB).SetH5SceneInfo("https://example.com", "example"),
+	} {
+		if payload.TradeType == TradeTypeJSAPI {
+			payload.OpenID = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+		}
+		if err := payload.PreSignCheck(); err != nil {
+			t.Errorf("trade_type %s: %v", payload.TradeType, err)
+		}
+	}
+	missing := []struct {
+		payload *UnifiedOrderPayload
+		field   string
+	}{
+		{testOrder(TradeTypeJSAPI), "openid"},
+		{testOrder(TradeTypeMWEB), "scene_info"},
+	}
+	for _, c := range missing {
+		err := c.payload.PreSignCheck()
+		if err == nil || !strings.Contains(err.Error(), c.field) {
+			t.Errorf("trade_type %s without %s: err = %v", c.payload.TradeType, c.field, err)
+		}
+	}
+}
+
+func TestUnifiedOrderJSAPIRequiresPrepayID(t *testing.T) {
+	payload := testOrder(TradeTypeJSAPI)
+	payload.OpenID = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+	server := newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><appid>wx123</appid><trade_type>JSAPI</trade_type><prepay_id></prepay_id></xml>", nil)
+	order, err := UnifiedOrderJSAPIWithClient(context.Background(), redirectClient(server), payload, testSecretKey)
+	if err == nil || order != nil {
+		t.Fatalf("order = %+v, err = %v, want error for empty prepay_id", order, err)
+	}
+
+	server = newXMLServer(t, http.StatusOK, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code><appid>wx123</appid><trade_type>JSAPI</trade_type><prepay_id>wx201410272009395522657a690389285100</prepay_id></xml>", nil)
+	order, err = UnifiedOrderJSAPIWithClient(context.Background(), redirectClient(server), payload, testSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PrepayID != "wx201410272009395522657a690389285100" || order.PayParams == nil || order.PayParams.Package != "prepay_id="+order.PrepayID {
+		t.Fatalf("order = %+v", order)
+	}
+}
+
+func TestAPPPayParamsJSONKeys(t *testing.T) {
+	response := &UnifiedOrderResp{AppId: "wx123", MchId: "10000100", TradeType: TradeTypeAPP, PrepayId: "wx201410272009395522657a690389285100"}
+	params := response.APPTyped(testSecretKey)
+	bs, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	if err = json.Unmarshal(bs, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// 与Android/iOS SDK PayReq的字段名一致
+	want := map[string]string{
+		"appid":     "wx123",
+		"partnerid": "10000100",
+		"prepayid":  "wx201410272009395522657a690389285100",
+		"package":   "Sign=WXPay",
+		"noncestr":  params.NonceStr,
+		"timestamp": params.Timestamp,
+		"sign":      params.Sign,
+	}
+	if len(fields) != len(want) {
+		t.Fatalf("JSON = %s, want keys %v", bs, want)
+	}
+	for k, v := range want {
+		if got, ok := fields[k]; !ok || got != v {
+			t.Errorf("%s = %q, want %q in %s", k, got, v, bs)
+		}
+	}
+	pm := map[string]interface{}{}
+	for k, v := range fields {
+		if k != "sign" {
+			pm[k] = v
+		}
+	}
+	if Sign(pm, testSecretKey) != params.Sign {
+		t.Fatal("sign does not cover the marshalled fields")
+	}
+}
@@ -0,0 +1,41 @@
+package weixin
+
+import (
+	"strings"
+)
+
+// ValidationError 签名前参数检查错误, 汇总全部缺失及非法字段
+type ValidationError struct {
+	Missing []string // 缺失字段
+	Invalid []string // 非法字段
+}
+
+func (this *ValidationError) Error() string {
+	parts := []string{}
+	if len(this.Missing) > 0 {
+		parts = append(parts, "missing: "+strings.Join(this.Missing, ", "))
+	}
+	if len(this.Invalid) > 0 {
+		parts = append(parts, "invalid: "+strings.Join(this.Invalid, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (this *ValidationError) require(field string, present bool) {
+	if !present {
+		this.Missing = append(this.Missing, field)
+	}
+}
+
+func (this *ValidationError) check(field string, valid bool) {
+	if !valid {
+		this.Invalid = append(this.Invalid, field)
+	}
+}
+
+func (this *ValidationError) err() error {
+	if len(this.Missing) == 0 && len(this.Invalid) == 0 {
+		return nil
+	}
+	return this
+}